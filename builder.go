@@ -8,9 +8,11 @@ import (
 
 // CodeBuilder provides a fluent API for generating Go code.
 type CodeBuilder struct {
-	ctx    *Context
-	lines  []string
-	indent int
+	ctx     *Context
+	lines   []string
+	indent  int
+	section string
+	ifEnd   int // len(lines) right after the last if or else-if block
 }
 
 // NewCodeBuilder creates a new CodeBuilder instance for building code.
@@ -24,7 +26,7 @@ func (c *Context) NewCodeBuilder() *CodeBuilder {
 // Line adds a formatted line of code with proper indentation.
 func (cb *CodeBuilder) Line(format string, args ...interface{}) *CodeBuilder {
 	indentStr := strings.Repeat("\t", cb.indent)
-	line := fmt.Sprintf(format, args...)
+	line := fmt.Sprintf(format, cb.qualifyArgs(args)...)
 	cb.lines = append(cb.lines, indentStr+line)
 
 	return cb
@@ -50,9 +52,18 @@ func (cb *CodeBuilder) Block(header string, fn func(*CodeBuilder)) *CodeBuilder
 	return cb
 }
 
-// Comment adds a single-line comment to the code.
+// Comment adds a line comment to the code. Text spanning several lines
+// becomes one comment line per line, and is never interpreted as a format.
 func (cb *CodeBuilder) Comment(text string) *CodeBuilder {
-	return cb.Line("// %s", text)
+	for _, line := range splitCommentLines(text) {
+		if line == "" {
+			cb.Line("//")
+		} else {
+			cb.Line("// %s", line)
+		}
+	}
+
+	return cb
 }
 
 // Package adds a package declaration.
@@ -62,7 +73,7 @@ func (cb *CodeBuilder) Package(name string) *CodeBuilder {
 
 // Import adds a single import statement.
 func (cb *CodeBuilder) Import(path string) *CodeBuilder {
-	return cb.Line("import \"%s\"", path)
+	return cb.Line("import %s", Quote(path))
 }
 
 // ImportBlock adds an import block with multiple imports.
@@ -79,7 +90,7 @@ func (cb *CodeBuilder) ImportBlock(imports []string) *CodeBuilder {
 
 	cb.indent++
 	for _, imp := range imports {
-		cb.Line("\"%s\"", imp)
+		cb.Line("%s", Quote(imp))
 	}
 
 	cb.indent--
@@ -90,17 +101,7 @@ func (cb *CodeBuilder) ImportBlock(imports []string) *CodeBuilder {
 
 // Struct creates a struct type definition.
 func (cb *CodeBuilder) Struct(name string, fn func(*StructBuilder)) *CodeBuilder {
-	sb := &StructBuilder{cb: cb}
-	cb.Line("type %s struct {", name)
-
-	cb.indent++
-
-	fn(sb)
-
-	cb.indent--
-	cb.Line("}")
-
-	return cb
+	return cb.GenericStruct(name, nil, fn)
 }
 
 // Function creates a function definition.
@@ -139,17 +140,7 @@ func (cb *CodeBuilder) DeclareAssign(left, right string) *CodeBuilder {
 
 // Interface creates an interface type definition.
 func (cb *CodeBuilder) Interface(name string, fn func(*InterfaceBuilder)) *CodeBuilder {
-	ib := &InterfaceBuilder{cb: cb}
-	cb.Line("type %s interface {", name)
-
-	cb.indent++
-
-	fn(ib)
-
-	cb.indent--
-	cb.Line("}")
-
-	return cb
+	return cb.GenericInterface(name, nil, fn)
 }
 
 // Const creates a single constant declaration.
@@ -203,7 +194,7 @@ func (cb *CodeBuilder) TypeAlias(name, typ string) *CodeBuilder {
 
 // If creates an if statement block.
 func (cb *CodeBuilder) If(condition string, fn func(*CodeBuilder)) *CodeBuilder {
-	return cb.Block("if "+condition, fn)
+	return cb.ifBlock("if "+condition, fn)
 }
 
 // IfErr creates an if err != nil block.
@@ -246,9 +237,10 @@ func (cb *CodeBuilder) Switch(expr string, fn func(*SwitchBuilder)) *CodeBuilder
 	return cb
 }
 
-// RawString adds a raw string literal using backticks.
+// RawString adds a string literal holding content, using backticks when
+// possible and an interpreted string otherwise; see RawOrQuoted.
 func (cb *CodeBuilder) RawString(content string) *CodeBuilder {
-	return cb.Line("`%s`", content)
+	return cb.Line("%s", RawOrQuoted(content))
 }
 
 // BuildTag adds a //go:build constraint comment.
@@ -261,8 +253,27 @@ func (cb *CodeBuilder) GoGenerate(tag string) *CodeBuilder {
 	return cb.Line("//go:generate %s", tag)
 }
 
-// Generate outputs all accumulated code to the context's output file.
+// LineDirective adds a //line directive pointing at a proto source location.
+// The directive is written without indentation as required by the Go compiler.
+func (cb *CodeBuilder) LineDirective(loc Location) *CodeBuilder {
+	if !loc.IsValid() {
+		return cb
+	}
+
+	cb.lines = append(cb.lines, fmt.Sprintf("//line %s:%d:%d", loc.SourceFile, loc.StartLine, loc.StartColumn))
+
+	return cb
+}
+
+// Generate outputs all accumulated code to the context's output file, or
+// to its section for builders created by Context.Section.
 func (cb *CodeBuilder) Generate() {
+	if cb.section != "" {
+		cb.GenerateIn(cb.section)
+
+		return
+	}
+
 	if cb.ctx.output == nil {
 		cb.ctx.createOutputFile()
 	}
@@ -274,24 +285,21 @@ func (cb *CodeBuilder) Generate() {
 
 // StructBuilder provides methods for building struct definitions.
 type StructBuilder struct {
-	cb *CodeBuilder
+	cb   *CodeBuilder
+	last *structField
 }
 
-// Field adds a field to the struct with optional tags.
+// Field adds a field to the struct with optional tags, such as
+// `json:"id"`. Use Tag to add tags key by key.
 func (sb *StructBuilder) Field(name, typ string, tags ...string) *StructBuilder {
-	line := fmt.Sprintf("%s %s", name, typ)
-	if len(tags) > 0 {
-		line += " `" + strings.Join(tags, " ") + "`"
-	}
-
-	sb.cb.Line("%s", line)
+	sb.addField(name+" "+typ, tags)
 
 	return sb
 }
 
 // EmbeddedField adds an embedded field to the struct.
 func (sb *StructBuilder) EmbeddedField(typ string) *StructBuilder {
-	sb.cb.Line("%s", typ)
+	sb.addField(typ, nil)
 
 	return sb
 }
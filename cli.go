@@ -0,0 +1,320 @@
+package ezproto
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// generateFunc produces a response for a request, such as Plugin.Generate.
+type generateFunc func(*pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error)
+
+// runGen implements the gen command, which runs generate without protoc:
+//
+//	gen --descriptor-set=api.binpb [--files=a.proto,b.proto] [--out=dir] [--param key=value]...
+//
+// The descriptor set must include the imports of the generated files, as
+// produced by "buf build -o" or "protoc --include_imports". Without --files,
+// the files that no other file in the set imports are generated.
+func runGen(args []string, generate generateFunc) error {
+	var (
+		descriptorSet string
+		files         string
+		out           string
+		params        []string
+	)
+
+	flags := flag.NewFlagSet("gen", flag.ContinueOnError)
+	flags.StringVar(&descriptorSet, "descriptor-set", "", "path to a binary FileDescriptorSet")
+	flags.StringVar(&files, "files", "", "comma-separated proto files to generate (default: files not imported by other files in the set)")
+	flags.StringVar(&out, "out", ".", "output directory")
+	flags.Func("param", "plugin parameter, may be repeated", func(s string) error {
+		params = append(params, s)
+
+		return nil
+	})
+
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("gen: %w", err)
+	}
+
+	if flags.NArg() > 0 {
+		return fmt.Errorf("gen: unexpected argument %q", flags.Arg(0))
+	}
+
+	if descriptorSet == "" {
+		return errors.New("gen: --descriptor-set is required")
+	}
+
+	set, err := ReadDescriptorSet(descriptorSet)
+	if err != nil {
+		return err
+	}
+
+	var toGenerate []string
+	if files != "" {
+		toGenerate = strings.Split(files, ",")
+	}
+
+	req, err := NewRequest(set, toGenerate, strings.Join(params, ","))
+	if err != nil {
+		return err
+	}
+
+	resp, err := generate(req)
+	if err != nil {
+		return err
+	}
+
+	return WriteResponse(resp, out)
+}
+
+// ReadDescriptorSet reads a binary FileDescriptorSet from path.
+func ReadDescriptorSet(path string) (*descriptorpb.FileDescriptorSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read descriptor set: %w", err)
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("failed to parse descriptor set %s: %w", path, err)
+	}
+
+	return set, nil
+}
+
+// NewRequest builds the CodeGeneratorRequest protoc would send for the given
+// files of a descriptor set. If files is empty, the files that no other file
+// in the set imports are generated, which leaves out dependencies such as
+// google/protobuf/*.proto. The set must contain all imports of the
+// generated files.
+func NewRequest(set *descriptorpb.FileDescriptorSet, files []string, parameter string) (*pluginpb.CodeGeneratorRequest, error) {
+	protoFiles, err := sortFiles(set.GetFile())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		files = rootFiles(set.GetFile())
+	}
+
+	for _, name := range files {
+		found := slices.ContainsFunc(protoFiles, func(f *descriptorpb.FileDescriptorProto) bool {
+			return f.GetName() == name
+		})
+		if !found {
+			return nil, fmt.Errorf("file %s is not in the descriptor set", name)
+		}
+	}
+
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: files,
+		ProtoFile:      protoFiles,
+	}
+
+	if parameter != "" {
+		req.Parameter = proto.String(parameter)
+	}
+
+	return req, nil
+}
+
+// rootFiles returns the names of the files that no other file imports, in
+// the order of the set.
+func rootFiles(files []*descriptorpb.FileDescriptorProto) []string {
+	imported := make(map[string]bool)
+
+	for _, f := range files {
+		for _, dep := range f.GetDependency() {
+			imported[dep] = true
+		}
+	}
+
+	var roots []string
+
+	for _, f := range files {
+		if !imported[f.GetName()] {
+			roots = append(roots, f.GetName())
+		}
+	}
+
+	return roots
+}
+
+// sortFiles orders files so that every file follows its imports, as protoc
+// does in a CodeGeneratorRequest.
+func sortFiles(files []*descriptorpb.FileDescriptorProto) ([]*descriptorpb.FileDescriptorProto, error) {
+	byName := make(map[string]*descriptorpb.FileDescriptorProto, len(files))
+	for _, f := range files {
+		byName[f.GetName()] = f
+	}
+
+	sorted := make([]*descriptorpb.FileDescriptorProto, 0, len(files))
+	state := make(map[string]int) // 1: visiting, 2: done
+
+	var visit func(f *descriptorpb.FileDescriptorProto) error
+
+	visit = func(f *descriptorpb.FileDescriptorProto) error {
+		switch state[f.GetName()] {
+		case 1:
+			return fmt.Errorf("import cycle through %s", f.GetName())
+		case 2:
+			return nil
+		}
+
+		state[f.GetName()] = 1
+
+		for _, dep := range f.GetDependency() {
+			d, ok := byName[dep]
+			if !ok {
+				return fmt.Errorf("%s imports %s, which is not in the descriptor set", f.GetName(), dep)
+			}
+
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+
+		state[f.GetName()] = 2
+		sorted = append(sorted, f)
+
+		return nil
+	}
+
+	for _, f := range files {
+		if err := visit(f); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
+
+// WriteResponse writes the files of a CodeGeneratorResponse below dir, the
+// way protoc does. Files with an insertion point are inserted into the file
+// generated earlier in the response, or else already on disk, under that
+// name. An error reported in the response is returned without writing
+// anything.
+func WriteResponse(resp *pluginpb.CodeGeneratorResponse, dir string) error {
+	outputs, err := resolveResponse(resp, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, out := range outputs {
+		if err := os.MkdirAll(filepath.Dir(out.path), 0o755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", out.name, err)
+		}
+
+		if err := os.WriteFile(out.path, out.content, 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", out.name, err)
+		}
+	}
+
+	return nil
+}
+
+// output is the final content of a file in a response.
+type output struct {
+	name    string
+	path    string
+	content []byte
+}
+
+// resolveResponse returns the final content of every file in resp, in the
+// order the files were first generated, with insertion points applied.
+func resolveResponse(resp *pluginpb.CodeGeneratorResponse, dir string) ([]*output, error) {
+	if resp.Error != nil {
+		return nil, errors.New(resp.GetError())
+	}
+
+	var outputs []*output
+
+	byName := make(map[string]*output)
+
+	for _, f := range resp.GetFile() {
+		name := filepath.FromSlash(f.GetName())
+		if !filepath.IsLocal(name) {
+			return nil, fmt.Errorf("refusing to write %s outside the output directory", f.GetName())
+		}
+
+		point := f.GetInsertionPoint()
+		if point == "" {
+			out := &output{name: f.GetName(), path: filepath.Join(dir, name), content: []byte(f.GetContent())}
+			if prev, ok := byName[out.name]; ok {
+				*prev = *out
+			} else {
+				byName[out.name] = out
+				outputs = append(outputs, out)
+			}
+
+			continue
+		}
+
+		out, ok := byName[f.GetName()]
+		if !ok {
+			existing, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s for insertion point %s: %w", f.GetName(), point, err)
+			}
+
+			out = &output{name: f.GetName(), path: filepath.Join(dir, name), content: existing}
+			byName[out.name] = out
+			outputs = append(outputs, out)
+		}
+
+		content, err := insertAt(out.content, point, []byte(f.GetContent()))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.GetName(), err)
+		}
+
+		out.content = content
+	}
+
+	return outputs, nil
+}
+
+// insertAt inserts content before the line holding the
+// @@protoc_insertion_point(point) marker, indented like the marker.
+func insertAt(file []byte, point string, content []byte) ([]byte, error) {
+	marker := []byte("@@protoc_insertion_point(" + point + ")")
+
+	i := bytes.Index(file, marker)
+	if i < 0 {
+		return nil, fmt.Errorf("insertion point %q not found", point)
+	}
+
+	lineStart := bytes.LastIndexByte(file[:i], '\n') + 1
+	indent := file[lineStart:i]
+	indent = indent[:len(indent)-len(bytes.TrimLeft(indent, " \t"))]
+
+	var buf bytes.Buffer
+
+	buf.Write(file[:lineStart])
+
+	for line := range bytes.Lines(content) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			buf.Write(indent)
+		}
+
+		buf.Write(line)
+	}
+
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+
+	buf.Write(file[lineStart:])
+
+	return buf.Bytes(), nil
+}
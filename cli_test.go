@@ -0,0 +1,180 @@
+package ezproto
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func TestNewRequest(t *testing.T) {
+	files := testFiles()
+
+	// Reverse the set so that every file comes before its imports
+	reversed := slices.Clone(files)
+	slices.Reverse(reversed)
+
+	tests := []struct {
+		name     string
+		files    []string
+		generate []string
+		wantErr  string
+	}{
+		{name: "roots", generate: []string{"internal/x.proto", "api/v2/users.proto"}},
+		{name: "explicit", files: []string{"api/v1/orders.proto"}, generate: []string{"api/v1/orders.proto"}},
+		{name: "missing", files: []string{"api/v3/missing.proto"}, wantErr: "file api/v3/missing.proto is not in the descriptor set"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := NewRequest(&descriptorpb.FileDescriptorSet{File: reversed}, tt.files, "a=b")
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("NewRequest() error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := req.GetFileToGenerate(); !slices.Equal(got, tt.generate) {
+				t.Errorf("FileToGenerate = %q, want %q", got, tt.generate)
+			}
+
+			// Files are sorted the way protoc sends them, imports first
+			var names []string
+			for _, f := range req.GetProtoFile() {
+				names = append(names, f.GetName())
+			}
+
+			want := []string{"internal/x.proto", "google/protobuf/descriptor.proto", "acme/options.proto", "api/v1/orders.proto", "api/v2/users.proto"}
+			if !slices.Equal(names, want) {
+				t.Errorf("ProtoFile = %q, want %q", names, want)
+			}
+
+			if req.GetParameter() != "a=b" {
+				t.Errorf("Parameter = %q, want %q", req.GetParameter(), "a=b")
+			}
+		})
+	}
+}
+
+func TestSortFilesErrors(t *testing.T) {
+	a := &descriptorpb.FileDescriptorProto{Name: proto.String("a.proto"), Dependency: []string{"b.proto"}}
+	b := &descriptorpb.FileDescriptorProto{Name: proto.String("b.proto"), Dependency: []string{"a.proto"}}
+	c := &descriptorpb.FileDescriptorProto{Name: proto.String("c.proto"), Dependency: []string{"d.proto"}}
+
+	tests := []struct {
+		files []*descriptorpb.FileDescriptorProto
+		want  string
+	}{
+		{[]*descriptorpb.FileDescriptorProto{a, b}, "import cycle through a.proto"},
+		{[]*descriptorpb.FileDescriptorProto{c}, "c.proto imports d.proto, which is not in the descriptor set"},
+	}
+
+	for _, tt := range tests {
+		if _, err := sortFiles(tt.files); err == nil || err.Error() != tt.want {
+			t.Errorf("sortFiles() error = %v, want %q", err, tt.want)
+		}
+	}
+}
+
+func TestRootFiles(t *testing.T) {
+	want := []string{"api/v2/users.proto", "internal/x.proto"}
+	if got := rootFiles(testFiles()); !slices.Equal(got, want) {
+		t.Errorf("rootFiles() = %q, want %q", got, want)
+	}
+}
+
+func TestRunGen(t *testing.T) {
+	dir := t.TempDir()
+	set := filepath.Join(dir, "set.binpb")
+	out := filepath.Join(dir, "out")
+
+	b, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: testFiles()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(set, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPlugin().GenerateFor("**", func(ctx *Context, file *File) error {
+		ctx.Code().Package(file.Package()).Line("const Name = %q", ctx.GetParameterWithDefault("name", "")).Generate()
+
+		return nil
+	})
+
+	args := []string{"ezproto", "gen", "--descriptor-set=" + set, "--files=internal/x.proto", "--out=" + out, "--param", "name=x"}
+	if err := p.run(args, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(out, "x.pb.go"))
+	if want := "package x\n\nconst Name = \"x\"\n"; err != nil || string(got) != want {
+		t.Errorf("x.pb.go = %q, %v, want %q", got, err, want)
+	}
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"gen"}, "gen: --descriptor-set is required"},
+		{[]string{"gen", "--descriptor-set=" + set, "extra"}, `gen: unexpected argument "extra"`},
+		{[]string{"gen", "--descriptor-set=" + filepath.Join(dir, "missing.binpb")}, "failed to read descriptor set"},
+		{[]string{"gen", "--descriptor-set=" + set, "--files=missing.proto"}, "file missing.proto is not in the descriptor set"},
+	} {
+		if err := p.run(append([]string{"ezproto"}, tt.args...), nil, nil); err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("run(%q) error = %v, want %q", tt.args, err, tt.want)
+		}
+	}
+}
+
+func TestWriteResponsePaths(t *testing.T) {
+	for _, name := range []string{"../escape.go", "/abs/escape.go", "a/../../escape.go"} {
+		dir := t.TempDir()
+
+		resp := &pluginpb.CodeGeneratorResponse{
+			File: []*pluginpb.CodeGeneratorResponse_File{{Name: proto.String(name), Content: proto.String("x")}},
+		}
+
+		if err := WriteResponse(resp, filepath.Join(dir, "out")); err == nil || !strings.Contains(err.Error(), "outside the output directory") {
+			t.Errorf("WriteResponse(%s) error = %v, want a refusal", name, err)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "escape.go")); err == nil {
+			t.Errorf("WriteResponse(%s) wrote outside the output directory", name)
+		}
+	}
+
+	dir := t.TempDir()
+
+	resp := &pluginpb.CodeGeneratorResponse{
+		File: []*pluginpb.CodeGeneratorResponse_File{
+			{Name: proto.String("a/b/c.go"), Content: proto.String("old")},
+			{Name: proto.String("a/b/c.go"), Content: proto.String("new")},
+		},
+	}
+
+	// Nested directories are created and later files replace earlier ones
+	if err := WriteResponse(resp, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := os.ReadFile(filepath.Join(dir, "a", "b", "c.go")); err != nil || string(got) != "new" {
+		t.Errorf("a/b/c.go = %q, %v, want %q", got, err, "new")
+	}
+
+	if err := WriteResponse(&pluginpb.CodeGeneratorResponse{Error: proto.String("boom")}, dir); err == nil || err.Error() != "boom" {
+		t.Errorf("WriteResponse() of an error response = %v, want boom", err)
+	}
+}
@@ -0,0 +1,495 @@
+package ezproto
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// configTag is the struct tag used to bind protoc parameters into a config struct.
+const configTag = "ezproto"
+
+// reservedParameters are handled by ezproto or protogen and are always accepted.
+var reservedParameters = []string{
+	"debug",
+	"package_mapping",
+	"log_file",
+	"log_format",
+	"log_level",
+	"plugin",
+	"dump_request",
+	"module",
+	"paths",
+	"annotate_code",
+	"default_api_level",
+}
+
+var (
+	durationType        = reflect.TypeFor[time.Duration]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// configField describes a single struct field bound to a protoc parameter.
+type configField struct {
+	name     string
+	index    []int
+	typ      reflect.Type
+	def      string
+	hasDef   bool
+	required bool
+	enum     []string
+	help     string
+}
+
+// configSpec describes all parameters accepted by a config struct.
+type configSpec struct {
+	fields []*configField
+	byName map[string]*configField
+}
+
+// WithConfig binds protoc parameters into the struct pointed to by cfg before
+// any generator runs.
+//
+// Fields are bound with tags of the form
+//
+//	Format  string            `ezproto:"format,default=json,enum=json|yaml" help:"output format"`
+//	Strict  bool              `ezproto:"strict"`
+//	Timeout time.Duration     `ezproto:"timeout,default=5s"`
+//	Exclude []string          `ezproto:"exclude"`
+//	Rename  map[string]string `ezproto:"rename,required"`
+//
+// Supported types are strings, booleans, integers, floats, time.Duration,
+// types implementing encoding.TextUnmarshaler, and slices and maps of those.
+// Slice fields collect one element per occurrence of the parameter, and map
+// fields expect "key:value" entries. Parameters that are neither declared in
+// cfg nor handled by ezproto or protogen are rejected with an error listing
+// the valid ones.
+func (p *Plugin) WithConfig(cfg any) *Plugin {
+	p.config = cfg
+
+	return p
+}
+
+// ParameterHelp returns a help text describing the parameters accepted by the
+// config registered with WithConfig.
+func (p *Plugin) ParameterHelp() string {
+	if p.config == nil {
+		return ""
+	}
+
+	spec, err := newConfigSpec(p.config)
+	if err != nil {
+		return err.Error()
+	}
+
+	return spec.help()
+}
+
+// newConfigSpec inspects the tags of the struct pointed to by cfg.
+func newConfigSpec(cfg any) (*configSpec, error) {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config must be a non-nil pointer to a struct, got %T", cfg)
+	}
+
+	spec := &configSpec{
+		byName: make(map[string]*configField),
+	}
+
+	t := v.Elem().Type()
+	for i := range t.NumField() {
+		sf := t.Field(i)
+
+		tag, ok := sf.Tag.Lookup(configTag)
+		if !ok || tag == "-" || !sf.IsExported() {
+			continue
+		}
+
+		field, err := parseConfigTag(sf, tag)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, exists := spec.byName[field.name]; exists {
+			return nil, fmt.Errorf("parameter %q is declared more than once", field.name)
+		}
+
+		spec.fields = append(spec.fields, field)
+		spec.byName[field.name] = field
+	}
+
+	return spec, nil
+}
+
+// parseConfigTag parses a `ezproto:"name,default=...,required,enum=a|b"` tag.
+func parseConfigTag(sf reflect.StructField, tag string) (*configField, error) {
+	parts := strings.Split(tag, ",")
+
+	field := &configField{
+		name:  parts[0],
+		index: sf.Index,
+		typ:   sf.Type,
+		help:  sf.Tag.Get("help"),
+	}
+
+	if field.name == "" {
+		field.name = toSnakeCase(sf.Name)
+	}
+
+	for _, opt := range parts[1:] {
+		key, value, _ := strings.Cut(opt, "=")
+
+		switch key {
+		case "required":
+			field.required = true
+		case "default":
+			field.def = value
+			field.hasDef = true
+		case "enum":
+			field.enum = strings.Split(value, "|")
+		default:
+			return nil, fmt.Errorf("field %s: unknown tag option %q", sf.Name, key)
+		}
+	}
+
+	if !isSupportedConfigType(field.typ) {
+		return nil, fmt.Errorf("field %s: unsupported type %s", sf.Name, field.typ)
+	}
+
+	return field, nil
+}
+
+// bind assigns parameter values to the config struct, applying defaults and
+// checking required and unknown parameters.
+func (spec *configSpec) bind(cfg any, values map[string][]string) error {
+	if err := spec.checkUnknown(values); err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(cfg).Elem()
+
+	var errs []error
+
+	for _, field := range spec.fields {
+		raw, ok := values[field.name]
+
+		switch {
+		case ok:
+		case field.hasDef:
+			raw = []string{field.def}
+		case field.required:
+			errs = append(errs, fmt.Errorf("missing required parameter %q", field.name))
+
+			continue
+		default:
+			continue
+		}
+
+		if err := field.set(v.FieldByIndex(field.index), raw); err != nil {
+			errs = append(errs, fmt.Errorf("parameter %q: %w", field.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkUnknown rejects parameters that no one is going to consume.
+func (spec *configSpec) checkUnknown(values map[string][]string) error {
+	var unknown []string
+
+	for key := range values {
+		if _, ok := spec.byName[key]; ok || isReservedParameter(key) {
+			continue
+		}
+
+		unknown = append(unknown, key)
+	}
+
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	sort.Strings(unknown)
+
+	valid := make([]string, 0, len(spec.fields))
+	for _, field := range spec.fields {
+		valid = append(valid, field.name)
+	}
+
+	return fmt.Errorf("unknown parameter %s (valid parameters: %s)",
+		strings.Join(quoteAll(unknown), ", "), strings.Join(valid, ", "))
+}
+
+// help renders the description of every declared parameter.
+func (spec *configSpec) help() string {
+	var sb strings.Builder
+
+	sb.WriteString("Parameters:\n")
+
+	for _, field := range spec.fields {
+		fmt.Fprintf(&sb, "  %s=<%s>", field.name, typeDescription(field.typ))
+
+		if field.help != "" {
+			fmt.Fprintf(&sb, "\n      %s", field.help)
+		}
+
+		var notes []string
+		if len(field.enum) > 0 {
+			notes = append(notes, "one of "+strings.Join(field.enum, ", "))
+		}
+
+		if field.hasDef {
+			notes = append(notes, "default "+field.def)
+		}
+
+		if field.required {
+			notes = append(notes, "required")
+		}
+
+		if field.typ.Kind() == reflect.Slice {
+			notes = append(notes, "repeatable")
+		}
+
+		if len(notes) > 0 {
+			fmt.Fprintf(&sb, "\n      (%s)", strings.Join(notes, "; "))
+		}
+
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// set assigns raw parameter values to a field.
+func (field *configField) set(v reflect.Value, raw []string) error {
+	switch field.typ.Kind() {
+	case reflect.Slice:
+		if reflect.PointerTo(field.typ).Implements(textUnmarshalerType) {
+			break
+		}
+
+		s := reflect.MakeSlice(field.typ, 0, len(raw))
+		for _, r := range raw {
+			elem := reflect.New(field.typ.Elem()).Elem()
+			if err := field.setScalar(elem, r); err != nil {
+				return err
+			}
+
+			s = reflect.Append(s, elem)
+		}
+
+		v.Set(s)
+
+		return nil
+	case reflect.Map:
+		m := reflect.MakeMapWithSize(field.typ, len(raw))
+		for _, r := range raw {
+			key, value, ok := strings.Cut(r, ":")
+			if !ok {
+				return fmt.Errorf("invalid map entry %q, want key:value", r)
+			}
+
+			elem := reflect.New(field.typ.Elem()).Elem()
+			if err := field.setScalar(elem, value); err != nil {
+				return err
+			}
+
+			m.SetMapIndex(reflect.ValueOf(key).Convert(field.typ.Key()), elem)
+		}
+
+		v.Set(m)
+
+		return nil
+	default:
+	}
+
+	return field.setScalar(v, raw[len(raw)-1])
+}
+
+// setScalar parses a single value into v.
+func (field *configField) setScalar(v reflect.Value, raw string) error {
+	if len(field.enum) > 0 && !slices.Contains(field.enum, raw) {
+		return fmt.Errorf("invalid value %q, want one of %s", raw, strings.Join(field.enum, ", "))
+	}
+
+	if reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		//nolint:forcetypeassert // checked by Implements above
+		u := v.Addr().Interface().(encoding.TextUnmarshaler)
+		if err := u.UnmarshalText([]byte(raw)); err != nil {
+			return fmt.Errorf("invalid value %q: %w", raw, err)
+		}
+
+		return nil
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", raw, err)
+		}
+
+		v.SetInt(int64(d))
+
+		return nil
+	}
+
+	return setBasic(v, raw)
+}
+
+// setBasic parses a value of a basic kind into v.
+func setBasic(v reflect.Value, raw string) error {
+	//nolint:exhaustive // unsupported kinds are rejected by isSupportedConfigType
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		if raw == "" {
+			raw = "true"
+		}
+
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 0, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 0, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", raw)
+		}
+
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+// isSupportedConfigType reports whether a field of type t can be bound.
+func isSupportedConfigType(t reflect.Type) bool {
+	if isSupportedScalar(t) {
+		return true
+	}
+
+	//nolint:exhaustive // only container kinds need special handling
+	switch t.Kind() {
+	case reflect.Slice:
+		return isSupportedScalar(t.Elem())
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && isSupportedScalar(t.Elem())
+	default:
+		return false
+	}
+}
+
+func isSupportedScalar(t reflect.Type) bool {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+
+	//nolint:exhaustive // every other kind is unsupported
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// typeDescription returns a short placeholder for a parameter type.
+func typeDescription(t reflect.Type) string {
+	switch {
+	case t == durationType:
+		return "duration"
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return "value"
+	case t.Kind() == reflect.Slice:
+		return typeDescription(t.Elem())
+	case t.Kind() == reflect.Map:
+		return "key:" + typeDescription(t.Elem())
+	case t.Kind() == reflect.Bool:
+		return "bool"
+	default:
+		return t.Kind().String()
+	}
+}
+
+// isReservedParameter reports whether key is consumed by ezproto or protogen.
+func isReservedParameter(key string) bool {
+	if slices.Contains(reservedParameters, key) {
+		return true
+	}
+
+	return isMappingParameter(key)
+}
+
+// isMappingParameter reports whether key is a protogen import path or API
+// level mapping for a proto file, e.g. Mfoo.proto=example.com/foo or
+// apilevelMfoo.proto=API_OPAQUE.
+func isMappingParameter(key string) bool {
+	for _, prefix := range []string{"M", "apilevelM"} {
+		if file, ok := strings.CutPrefix(key, prefix); ok && len(file) > len(".proto") && strings.HasSuffix(file, ".proto") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// toSnakeCase converts a Go identifier such as OutputDir or HTTPPort into
+// output_dir or http_port.
+func toSnakeCase(s string) string {
+	runes := []rune(s)
+
+	var sb strings.Builder
+
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				sb.WriteByte('_')
+			}
+
+			r = unicode.ToLower(r)
+		}
+
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
+
+func quoteAll(values []string) []string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, strconv.Quote(v))
+	}
+
+	return quoted
+}
@@ -0,0 +1,218 @@
+package ezproto
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Format  string            `ezproto:"format,default=json,enum=json|yaml" help:"output format"`
+	Strict  bool              `ezproto:"strict"`
+	Timeout time.Duration     `ezproto:"timeout,default=5s"`
+	Limit   int               `ezproto:"limit"`
+	Exclude []string          `ezproto:"exclude"`
+	Rename  map[string]string `ezproto:"rename"`
+	OutDir  string            `ezproto:""`
+	Ignored string
+}
+
+func TestConfigBind(t *testing.T) {
+	tests := []struct {
+		name    string
+		param   string
+		want    testConfig
+		wantErr string
+	}{
+		{
+			name:  "defaults",
+			param: "",
+			want:  testConfig{Format: "json", Timeout: 5 * time.Second},
+		},
+		{
+			name:  "values",
+			param: "format=yaml,strict,timeout=1m,limit=0x10,out_dir=gen",
+			want:  testConfig{Format: "yaml", Strict: true, Timeout: time.Minute, Limit: 16, OutDir: "gen"},
+		},
+		{
+			name:  "repeated",
+			param: "exclude=a,exclude=b,rename=Foo:Bar,rename=Baz:Qux",
+			want: testConfig{
+				Format:  "json",
+				Timeout: 5 * time.Second,
+				Exclude: []string{"a", "b"},
+				Rename:  map[string]string{"Foo": "Bar", "Baz": "Qux"},
+			},
+		},
+		{
+			name:  "reserved parameters",
+			param: "paths=source_relative,Mfoo/bar.proto=example.com/bar,apilevelMfoo/bar.proto=API_OPAQUE,debug",
+			want:  testConfig{Format: "json", Timeout: 5 * time.Second},
+		},
+		{
+			name:    "enum",
+			param:   "format=toml",
+			wantErr: `parameter "format": invalid value "toml", want one of json, yaml`,
+		},
+		{
+			name:    "invalid integer",
+			param:   "limit=ten",
+			wantErr: `parameter "limit": invalid integer "ten"`,
+		},
+		{
+			name:    "invalid map entry",
+			param:   "rename=Foo",
+			wantErr: `parameter "rename": invalid map entry "Foo", want key:value`,
+		},
+		{
+			name:    "unknown",
+			param:   "fromat=yaml,Mfoo=bar",
+			wantErr: `unknown parameter "Mfoo", "fromat" (valid parameters: format, strict, timeout, limit, exclude, rename, out_dir)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := parseParameters(tt.param)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var cfg testConfig
+
+			spec, err := newConfigSpec(&cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = spec.bind(&cfg, params.grouped())
+
+			switch {
+			case tt.wantErr != "":
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("bind(%q) error = %v, want %s", tt.param, err, tt.wantErr)
+				}
+			case err != nil:
+				t.Fatalf("bind(%q) error = %v", tt.param, err)
+			case !reflect.DeepEqual(cfg, tt.want):
+				t.Errorf("bind(%q) = %+v, want %+v", tt.param, cfg, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigSpecErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     any
+		wantErr string
+	}{
+		{
+			name:    "not a pointer",
+			cfg:     testConfig{},
+			wantErr: "config must be a non-nil pointer to a struct, got ezproto.testConfig",
+		},
+		{
+			name: "unknown option",
+			cfg: &struct {
+				A string `ezproto:"a,optional"`
+			}{},
+			wantErr: `field A: unknown tag option "optional"`,
+		},
+		{
+			name: "unsupported type",
+			cfg: &struct {
+				A []map[string]string `ezproto:"a"`
+			}{},
+			wantErr: "field A: unsupported type []map[string]string",
+		},
+		{
+			name: "duplicate",
+			cfg: &struct {
+				A string `ezproto:"a"`
+				B string `ezproto:"a"`
+			}{},
+			wantErr: `parameter "a" is declared more than once`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newConfigSpec(tt.cfg); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("newConfigSpec() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigRequired(t *testing.T) {
+	var cfg struct {
+		Module string `ezproto:"go_module,required"`
+	}
+
+	spec, err := newConfigSpec(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := spec.bind(&cfg, nil); err == nil || err.Error() != `missing required parameter "go_module"` {
+		t.Errorf("bind() error = %v, want missing required parameter", err)
+	}
+}
+
+func TestParameterHelp(t *testing.T) {
+	help := NewPlugin().WithConfig(&testConfig{}).ParameterHelp()
+
+	for _, want := range []string{
+		"  format=<string>\n      output format\n      (one of json, yaml; default json)\n",
+		"  timeout=<duration>\n      (default 5s)\n",
+		"  exclude=<string>\n      (repeatable)\n",
+		"  rename=<key:string>\n",
+	} {
+		if !strings.Contains(help, want) {
+			t.Errorf("ParameterHelp() = %q, want it to contain %q", help, want)
+		}
+	}
+}
+
+func TestIsReservedParameter(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"paths", true},
+		{"dump_request", true},
+		{"Mfoo.proto", true},
+		{"Mfoo/bar.proto", true},
+		{"apilevelMfoo.proto", true},
+		{"M", false},
+		{"M.proto", false},
+		{"Mode", false},
+		{"Mfoo", false},
+		{"apilevelM", false},
+		{"format", false},
+	}
+
+	for _, tt := range tests {
+		if got := isReservedParameter(tt.key); got != tt.want {
+			t.Errorf("isReservedParameter(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestToSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"OutputDir": "output_dir",
+		"HTTPPort":  "http_port",
+		"ID":        "id",
+		"already":   "already",
+		"userID":    "user_id",
+	}
+
+	for in, want := range tests {
+		if got := toSnakeCase(in); got != want {
+			t.Errorf("toSnakeCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -1,8 +1,9 @@
 package ezproto
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"log/slog"
 	"path/filepath"
 	"strings"
 
@@ -14,8 +15,15 @@ type Context struct {
 	plugin     *Plugin
 	gen        *protogen.Plugin
 	file       *protogen.File
+	model      *model
+	element    Element
+	generator  string
+	order      int
+	importPath protogen.GoImportPath
 	output     GeneratedFile
+	deferred   *deferredOutputs
 	parameters map[string]string
+	params     parameters
 }
 
 // GeneratedFile interface for abstraction.
@@ -35,7 +43,7 @@ func (c *Context) NewOutputFile(filename string) GeneratedFile {
 		filename += ".go"
 	}
 
-	c.output = c.gen.NewGeneratedFile(filename, c.file.GoImportPath)
+	c.output = c.newGeneratedFile(filename, c.importPath)
 
 	return c.output
 }
@@ -47,7 +55,16 @@ func (c *Context) createOutputFile() {
 
 	base := filepath.Base(c.file.Desc.Path())
 	name := strings.TrimSuffix(base, ".proto") + ".pb.go"
-	c.output = c.gen.NewGeneratedFile(name, c.file.GoImportPath)
+	c.output = c.newGeneratedFile(name, c.file.GoImportPath)
+}
+
+// newGeneratedFile creates an output file, deferring it when running in a worker.
+func (c *Context) newGeneratedFile(filename string, importPath protogen.GoImportPath) GeneratedFile {
+	if c.deferred != nil {
+		return c.deferred.newGeneratedFile(filename, importPath)
+	}
+
+	return c.gen.NewGeneratedFile(filename, importPath)
 }
 
 // Import imports a package and returns its qualified identifier.
@@ -61,34 +78,66 @@ func (c *Context) Import(importPath string) string {
 	})
 }
 
+// File returns the proto file being generated, or nil for outputs created
+// by a RequestContext.
+func (c *Context) File() *File {
+	if c.file == nil {
+		return nil
+	}
+
+	return c.model.byPath[c.file.Desc.Path()]
+}
+
+// Element returns the element visited by a per-element generator such as
+// ForEachMessage, or nil in file generators.
+func (c *Context) Element() Element {
+	return c.element
+}
+
 // Files returns all proto files that are being generated.
 func (c *Context) Files() []*File {
-	var files []*File
-
-	for _, f := range c.gen.Files {
-		if f.Generate {
-			files = append(files, &File{
-				proto: f,
-				Name:  f.Desc.Path(),
-			})
-		}
-	}
+	return c.model.generated()
+}
+
+// FileByPath returns the file with the given path, including imported files.
+func (c *Context) FileByPath(path string) (*File, bool) {
+	file, ok := c.model.byPath[path]
 
-	return files
+	return file, ok
 }
 
-// Debugf prints debug messages if debug mode is enabled.
+// Debugf logs a formatted message at debug level through Logger.
+// Records are only emitted in debug mode or with log_level=debug.
 func (c *Context) Debugf(format string, args ...interface{}) {
-	if c.plugin.options.Debug {
-		fmt.Fprintf(os.Stderr, "[DEBUG] "+format+"\n", args...)
+	logger := c.Logger()
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
 	}
+
+	logger.Debug(fmt.Sprintf(format, args...))
 }
 
 // Parameters returns the plugin parameters passed from protoc.
+// Only the last value of a repeated parameter is kept, and parameters given
+// without a value map to "true"; use ParameterValues and HasFlag to tell
+// these cases apart.
 func (c *Context) Parameters() map[string]string {
 	return c.parameters
 }
 
+// ParameterValues returns every value given for a parameter, in the order
+// protoc passed them. A parameter given without a value contributes an
+// empty string.
+func (c *Context) ParameterValues(key string) []string {
+	return c.params.values(key)
+}
+
+// HasFlag returns true if the parameter was given without a value,
+// such as "debug" as opposed to "debug=true".
+func (c *Context) HasFlag(key string) bool {
+	return c.params.flag(key)
+}
+
 // GetParameter returns a specific parameter value.
 func (c *Context) GetParameter(key string) (string, bool) {
 	value, exists := c.parameters[key]
@@ -0,0 +1,182 @@
+package ezproto
+
+import (
+	"cmp"
+	"strings"
+)
+
+// IfInit creates an if statement with an init statement, such as
+// if err := f(); err != nil.
+func (cb *CodeBuilder) IfInit(init, condition string, fn func(*CodeBuilder)) *CodeBuilder {
+	return cb.ifBlock("if "+init+"; "+condition, fn)
+}
+
+// ElseIf continues the if statement written just before with an else-if
+// branch. It panics if the previous statement is not an if statement.
+func (cb *CodeBuilder) ElseIf(condition string, fn func(*CodeBuilder)) *CodeBuilder {
+	cb.reopenIf("else if")
+
+	return cb.ifBlock("} else if "+condition, fn)
+}
+
+// Else continues the if statement written just before with an else branch.
+// It panics if the previous statement is not an if statement.
+func (cb *CodeBuilder) Else(fn func(*CodeBuilder)) *CodeBuilder {
+	cb.reopenIf("else")
+
+	return cb.Block("} else", fn)
+}
+
+// ifBlock writes an if or else-if block and records where it ends, so that
+// ElseIf and Else can tell whether they directly follow it.
+func (cb *CodeBuilder) ifBlock(header string, fn func(*CodeBuilder)) *CodeBuilder {
+	cb.Block(header, fn)
+	cb.ifEnd = len(cb.lines)
+
+	return cb
+}
+
+// reopenIf removes the closing brace of the if or else-if block written
+// just before, so that clause can continue it.
+func (cb *CodeBuilder) reopenIf(clause string) {
+	if cb.ifEnd == 0 || cb.ifEnd != len(cb.lines) {
+		panic("ezproto: " + clause + " must directly follow an If, IfInit or ElseIf")
+	}
+
+	cb.lines = cb.lines[:len(cb.lines)-1]
+	cb.ifEnd = 0
+}
+
+// While creates a loop that runs while condition holds.
+func (cb *CodeBuilder) While(condition string, fn func(*CodeBuilder)) *CodeBuilder {
+	return cb.Block("for "+condition, fn)
+}
+
+// Loop creates an infinite loop.
+func (cb *CodeBuilder) Loop(fn func(*CodeBuilder)) *CodeBuilder {
+	return cb.Block("for", fn)
+}
+
+// ForRangeKV creates a range loop declaring key and value with :=. Either
+// name may be empty: an empty value is omitted, an empty key becomes _ when
+// a value is given, and with neither the loop is written as for range x.
+// Ranging over integers and iterator functions uses the same forms.
+func (cb *CodeBuilder) ForRangeKV(key, value, iterable string, fn func(*CodeBuilder)) *CodeBuilder {
+	return cb.Block(rangeClause(key, value, ":=", iterable), fn)
+}
+
+// ForRangeAssign creates a range loop assigning to existing variables
+// with =, such as for k, v = range m.
+func (cb *CodeBuilder) ForRangeAssign(key, value, iterable string, fn func(*CodeBuilder)) *CodeBuilder {
+	return cb.Block(rangeClause(key, value, "=", iterable), fn)
+}
+
+// ForTimes creates a loop ranging over the integers 0 to n-1, such as
+// for i := range n. An empty variable writes for range n.
+func (cb *CodeBuilder) ForTimes(variable, n string, fn func(*CodeBuilder)) *CodeBuilder {
+	return cb.ForRangeKV(variable, "", n, fn)
+}
+
+func rangeClause(key, value, op, iterable string) string {
+	switch {
+	case key == "" && value == "":
+		return "for range " + iterable
+	case value == "":
+		return "for " + key + " " + op + " range " + iterable
+	default:
+		return "for " + cmp.Or(key, "_") + ", " + value + " " + op + " range " + iterable
+	}
+}
+
+// TypeSwitch creates a type switch on expr. If bind is not empty, the
+// value is bound to it in each case, as in switch v := expr.(type).
+func (cb *CodeBuilder) TypeSwitch(bind, expr string, fn func(*SwitchBuilder)) *CodeBuilder {
+	guard := expr + ".(type)"
+	if bind != "" {
+		guard = bind + " := " + guard
+	}
+
+	return cb.Switch(guard, fn)
+}
+
+// Select creates a select statement. Case clauses hold communications,
+// such as "v := <-ch" or "out <- v".
+func (cb *CodeBuilder) Select(fn func(*SwitchBuilder)) *CodeBuilder {
+	sb := &SwitchBuilder{cb: cb}
+	cb.Line("select {")
+
+	cb.indent++
+
+	fn(sb)
+
+	cb.indent--
+	cb.Line("}")
+
+	return cb
+}
+
+// Defer adds a defer statement for call.
+func (cb *CodeBuilder) Defer(call string) *CodeBuilder {
+	return cb.Line("defer %s", call)
+}
+
+// DeferFunc defers a closure: defer func() { ... }().
+func (cb *CodeBuilder) DeferFunc(fn func(*CodeBuilder)) *CodeBuilder {
+	return cb.Closure("defer ", "()", "()", fn)
+}
+
+// Go adds a go statement for call.
+func (cb *CodeBuilder) Go(call string) *CodeBuilder {
+	return cb.Line("go %s", call)
+}
+
+// GoFunc starts a closure in a goroutine: go func() { ... }().
+func (cb *CodeBuilder) GoFunc(fn func(*CodeBuilder)) *CodeBuilder {
+	return cb.Closure("go ", "()", "()", fn)
+}
+
+// Closure writes a function literal whose body is built by fn, between
+// prefix and suffix on the surrounding lines. The signature holds the
+// parameters and results:
+//
+//	cb.Closure("handler := ", "(w http.ResponseWriter, r *http.Request)", "", body)
+//	cb.Closure("sort.Slice(xs, ", "(i, j int) bool", ")", body)
+func (cb *CodeBuilder) Closure(prefix, signature, suffix string, fn func(*CodeBuilder)) *CodeBuilder {
+	cb.Line("%sfunc%s {", prefix, signature)
+
+	cb.indent++
+	fn(cb)
+
+	cb.indent--
+	cb.Line("}%s", suffix)
+
+	return cb
+}
+
+// Label adds a label for the statement that follows, such as a loop
+// targeted by Break or Continue. Labels are outdented like gofmt does.
+func (cb *CodeBuilder) Label(name string) *CodeBuilder {
+	cb.lines = append(cb.lines, strings.Repeat("\t", max(cb.indent-1, 0))+name+":")
+
+	return cb
+}
+
+// Break adds a break statement, optionally targeting a label.
+func (cb *CodeBuilder) Break(label ...string) *CodeBuilder {
+	return cb.Line("%s", strings.Join(append([]string{"break"}, label...), " "))
+}
+
+// Continue adds a continue statement, optionally targeting a label.
+func (cb *CodeBuilder) Continue(label ...string) *CodeBuilder {
+	return cb.Line("%s", strings.Join(append([]string{"continue"}, label...), " "))
+}
+
+// Goto adds a goto statement.
+func (cb *CodeBuilder) Goto(label string) *CodeBuilder {
+	return cb.Line("goto %s", label)
+}
+
+// Fallthrough adds a fallthrough statement to a switch case.
+func (cb *CodeBuilder) Fallthrough() *CodeBuilder {
+	return cb.Line("fallthrough")
+}
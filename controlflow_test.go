@@ -0,0 +1,112 @@
+package ezproto
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIfElse(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(cb *CodeBuilder)
+		want  string
+	}{
+		{
+			name: "else",
+			build: func(cb *CodeBuilder) {
+				cb.If("a", func(cb *CodeBuilder) { cb.Line("x()") }).
+					Else(func(cb *CodeBuilder) { cb.Line("y()") })
+			},
+			want: "\tif a {\n\t\tx()\n\t} else {\n\t\ty()\n\t}\n",
+		},
+		{
+			name: "else if chain",
+			build: func(cb *CodeBuilder) {
+				cb.IfInit("v, ok := m[k]", "ok", func(cb *CodeBuilder) { cb.Return("v") }).
+					ElseIf("b", func(*CodeBuilder) {}).
+					ElseIf("c", func(cb *CodeBuilder) { cb.Line("z()") }).
+					Else(func(cb *CodeBuilder) { cb.Return("0") })
+			},
+			want: "\tif v, ok := m[k]; ok {\n\t\treturn v\n\t} else if b {\n\t} else if c {\n\t\tz()\n\t} else {\n\t\treturn 0\n\t}\n",
+		},
+		{
+			name: "label in branch",
+			build: func(cb *CodeBuilder) {
+				cb.If("a", func(cb *CodeBuilder) {
+					cb.Label("L")
+					cb.Line("for {}")
+				}).Else(func(cb *CodeBuilder) { cb.Line("y()") })
+			},
+			want: "\tif a {\n\tL:\n\t\tfor {\n\t\t}\n\t} else {\n\t\ty()\n\t}\n",
+		},
+		{
+			name: "nested if in branch",
+			build: func(cb *CodeBuilder) {
+				cb.If("a", func(cb *CodeBuilder) {
+					cb.If("b", func(cb *CodeBuilder) { cb.Line("x()") })
+				}).Else(func(cb *CodeBuilder) { cb.Line("y()") })
+			},
+			want: "\tif a {\n\t\tif b {\n\t\t\tx()\n\t\t}\n\t} else {\n\t\ty()\n\t}\n",
+		},
+		{
+			name: "statements in branch",
+			build: func(cb *CodeBuilder) {
+				cb.If("a", func(cb *CodeBuilder) {
+					cb.Stmt(
+						IfStmt(Id("b"), ExprStmt(Id("x").Call())),
+						DefineStmt([]string{"s"}, Raw("`one\n} else {\n`")),
+					)
+				}).Else(func(cb *CodeBuilder) { cb.Line("y()") })
+			},
+			want: "\tif a {\n\t\tif b {\n\t\t\tx()\n\t\t}\n\t\ts := `one\n} else {\n`\n\t} else {\n\t\ty()\n\t}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := renderFor(t, func(cb *CodeBuilder) {
+				cb.Function("F()", tt.build)
+			})
+
+			want := "func F() {\n" + tt.want + "}\n"
+			if !strings.HasSuffix(content, want) {
+				t.Errorf("generated\n%s\nwant it to end with\n%s", content, want)
+			}
+		})
+	}
+}
+
+func TestMisplacedElse(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(cb *CodeBuilder)
+		want  string
+	}{
+		{"first statement", func(cb *CodeBuilder) { cb.Else(func(*CodeBuilder) {}) }, "else"},
+		{"after a loop", func(cb *CodeBuilder) {
+			cb.Loop(func(*CodeBuilder) {}).ElseIf("a", func(*CodeBuilder) {})
+		}, "else if"},
+		{"after a statement", func(cb *CodeBuilder) {
+			cb.If("a", func(*CodeBuilder) {}).Line("x()").Else(func(*CodeBuilder) {})
+		}, "else"},
+		{"after else", func(cb *CodeBuilder) {
+			cb.If("a", func(*CodeBuilder) {}).Else(func(*CodeBuilder) {}).Else(func(*CodeBuilder) {})
+		}, "else"},
+		{"inside the if", func(cb *CodeBuilder) {
+			cb.If("a", func(cb *CodeBuilder) { cb.Else(func(*CodeBuilder) {}) })
+		}, "else"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				want := "ezproto: " + tt.want + " must directly follow an If, IfInit or ElseIf"
+				if got := recover(); got != want {
+					t.Errorf("recovered %v, want %q", got, want)
+				}
+			}()
+
+			tt.build((&Context{}).NewCodeBuilder())
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package ezproto
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// edit is a single line of a line-based diff.
+type edit struct {
+	op   byte // ' ', '-' or '+'
+	text string
+}
+
+// unifiedDiff returns a unified diff turning before into after, or an empty
+// string if they are equal.
+func unifiedDiff(beforeName, afterName string, before, after []byte) string {
+	if bytes.Equal(before, after) {
+		return ""
+	}
+
+	edits := diffLines(splitLines(before), splitLines(after))
+
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", beforeName, afterName)
+
+	// Line numbers in before and after at the start of each edit
+	beforeLine := make([]int, len(edits)+1)
+	afterLine := make([]int, len(edits)+1)
+
+	for i, e := range edits {
+		beforeLine[i+1], afterLine[i+1] = beforeLine[i], afterLine[i]
+
+		if e.op != '+' {
+			beforeLine[i+1]++
+		}
+
+		if e.op != '-' {
+			afterLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(edits); {
+		for i < len(edits) && edits[i].op == ' ' {
+			i++
+		}
+
+		if i == len(edits) {
+			break
+		}
+
+		start, end := max(i-diffContext, 0), hunkEnd(edits, i)
+
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n",
+			hunkRange(beforeLine[start], beforeLine[end]-beforeLine[start]),
+			hunkRange(afterLine[start], afterLine[end]-afterLine[start]))
+
+		for _, e := range edits[start:end] {
+			fmt.Fprintf(&sb, "%c%s\n", e.op, e.text)
+		}
+
+		i = end
+	}
+
+	return sb.String()
+}
+
+// hunkEnd returns the end of the hunk containing the change at i, merging
+// changes separated by at most twice the context.
+func hunkEnd(edits []edit, i int) int {
+	end := i
+
+	for {
+		for end < len(edits) && edits[end].op != ' ' {
+			end++
+		}
+
+		next := end
+		for next < len(edits) && edits[next].op == ' ' && next-end < 2*diffContext {
+			next++
+		}
+
+		if next == len(edits) || edits[next].op == ' ' {
+			return min(end+diffContext, len(edits))
+		}
+
+		end = next
+	}
+}
+
+// hunkRange formats the start and length of a hunk side.
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+// splitLines splits content into lines without their line endings.
+func splitLines(content []byte) []string {
+	s := strings.TrimSuffix(string(content), "\n")
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, "\n")
+}
+
+// maxEditDistance bounds the work of diffLines. The search keeps O(D²)
+// state for an edit distance D, so larger changes are reported as a single
+// replacement of the differing lines.
+const maxEditDistance = 2000
+
+// diffLines computes a minimal line diff of the lines between the common
+// prefix and suffix with Myers' algorithm. Within a change, removed lines
+// come before added lines.
+func diffLines(a, b []string) []edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]edit, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		edits = append(edits, edit{' ', line})
+	}
+
+	edits = append(edits, groupChanges(myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]))...)
+
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, edit{' ', line})
+	}
+
+	return edits
+}
+
+// myers returns a shortest edit script turning a into b, or a replacement
+// of all of a by all of b if more than maxEditDistance edits are needed.
+func myers(a, b []string) []edit {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+
+	// v[offset+k] is the furthest x reached on diagonal k = x-y; trace keeps
+	// the diagonals -d..d of v after each step d for backtracking
+	v := make([]int, 2*offset+1)
+	trace := make([][]int, 0, min(n+m, maxEditDistance)+1)
+
+	for d := 0; d <= min(n+m, maxEditDistance); d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+
+				return backtrack(a, b, trace)
+			}
+		}
+
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+	}
+
+	edits := make([]edit, 0, n+m)
+	for _, line := range a {
+		edits = append(edits, edit{'-', line})
+	}
+
+	for _, line := range b {
+		edits = append(edits, edit{'+', line})
+	}
+
+	return edits
+}
+
+// backtrack rebuilds the edit script from the trace of myers.
+func backtrack(a, b []string, trace [][]int) []edit {
+	var edits []edit
+
+	x, y := len(a), len(b)
+
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		at := func(k int) int { return prev[k+d-1] }
+
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{' ', a[x]})
+		}
+
+		if x == prevX {
+			y--
+			edits = append(edits, edit{'+', b[y]})
+		} else {
+			x--
+			edits = append(edits, edit{'-', a[x]})
+		}
+	}
+
+	for x > 0 {
+		x--
+		edits = append(edits, edit{' ', a[x]})
+	}
+
+	slices.Reverse(edits)
+
+	return edits
+}
+
+// groupChanges reorders every run of changed lines so that removals come
+// before additions, as in the usual unified diff layout.
+func groupChanges(edits []edit) []edit {
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			i++
+
+			continue
+		}
+
+		end := i
+		for end < len(edits) && edits[end].op != ' ' {
+			end++
+		}
+
+		slices.SortStableFunc(edits[i:end], func(x, y edit) int {
+			return cmp.Compare(y.op, x.op) // '-' sorts before '+'
+		})
+
+		i = end
+	}
+
+	return edits
+}
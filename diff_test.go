@@ -0,0 +1,161 @@
+package ezproto
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	numbers := func(lines ...string) string {
+		return strings.Join(lines, "\n") + "\n"
+	}
+
+	tests := []struct {
+		name          string
+		before, after string
+		want          string
+	}{
+		{
+			name:   "equal",
+			before: "a\nb\n",
+			after:  "a\nb\n",
+			want:   "",
+		},
+		{
+			name:   "changed line",
+			before: "x\ny\nz\n",
+			after:  "x\nY\nz\n",
+			want:   "--- a\n+++ b\n@@ -1,3 +1,3 @@\n x\n-y\n+Y\n z\n",
+		},
+		{
+			name:   "new file",
+			before: "",
+			after:  "x\ny\n",
+			want:   "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+x\n+y\n",
+		},
+		{
+			name:   "removed file",
+			before: "x\ny\n",
+			after:  "",
+			want:   "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-x\n-y\n",
+		},
+		{
+			name:   "merged hunks",
+			before: numbers("1", "2", "3", "4", "5", "6", "7", "8", "9"),
+			after:  numbers("1", "X", "3", "4", "5", "6", "7", "Y", "9"),
+			want:   "--- a\n+++ b\n@@ -1,9 +1,9 @@\n 1\n-2\n+X\n 3\n 4\n 5\n 6\n 7\n-8\n+Y\n 9\n",
+		},
+		{
+			name:   "separate hunks",
+			before: numbers("1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13", "14", "15", "16"),
+			after:  numbers("1", "X", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13", "14", "Y", "16"),
+			want: "--- a\n+++ b\n" +
+				"@@ -1,5 +1,5 @@\n 1\n-2\n+X\n 3\n 4\n 5\n" +
+				"@@ -12,5 +12,5 @@\n 12\n 13\n 14\n-15\n+Y\n 16\n",
+		},
+		{
+			name:   "removals before additions",
+			before: "a\nb\nc\n",
+			after:  "x\nb\ny\n",
+			want:   "--- a\n+++ b\n@@ -1,3 +1,3 @@\n-a\n+x\n b\n-c\n+y\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("a", "b", []byte(tt.before), []byte(tt.after)); got != tt.want {
+				t.Errorf("unifiedDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesMinimal(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	randomLines := func() []string {
+		lines := make([]string, rng.IntN(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.IntN(4)))
+		}
+
+		return lines
+	}
+
+	for range 2000 {
+		a, b := randomLines(), randomLines()
+		edits := diffLines(a, b)
+
+		before, after, common := applyEdits(edits)
+		if strings.Join(before, "") != strings.Join(a, "") || strings.Join(after, "") != strings.Join(b, "") {
+			t.Fatalf("diffLines(%q, %q) = %v does not turn one into the other", a, b, edits)
+		}
+
+		if want := lcsLength(a, b); common != want {
+			t.Fatalf("diffLines(%q, %q) keeps %d lines, want %d", a, b, common, want)
+		}
+	}
+}
+
+func TestDiffLinesEditDistanceCap(t *testing.T) {
+	n := maxEditDistance + 10
+
+	a := make([]string, n)
+	b := make([]string, n)
+
+	for i := range n {
+		a[i], b[i] = "a"+strings.Repeat("x", i%7), "b"+strings.Repeat("x", i%7)
+	}
+
+	edits := diffLines(a, b)
+
+	before, after, _ := applyEdits(edits)
+	if len(before) != n || len(after) != n {
+		t.Fatalf("diffLines() rebuilt %d and %d lines, want %d", len(before), len(after), n)
+	}
+
+	if edits[0].op != '-' || edits[n].op != '+' {
+		t.Errorf("diffLines() past the cap = %c...%c, want all removals then all additions", edits[0].op, edits[n].op)
+	}
+}
+
+// applyEdits returns the two sides of a diff and the number of common lines.
+func applyEdits(edits []edit) (before, after []string, common int) {
+	for _, e := range edits {
+		if e.op != '+' {
+			before = append(before, e.text)
+		}
+
+		if e.op != '-' {
+			after = append(after, e.text)
+		}
+
+		if e.op == ' ' {
+			common++
+		}
+	}
+
+	return before, after, common
+}
+
+// lcsLength returns the length of the longest common subsequence of a and b.
+func lcsLength(a, b []string) int {
+	prev := make([]int, len(b)+1)
+
+	for i := range a {
+		cur := make([]int, len(b)+1)
+
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+
+		prev = cur
+	}
+
+	return prev[len(b)]
+}
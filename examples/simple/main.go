@@ -6,7 +6,7 @@ import (
 
 // HelperGenerator generates helper structs for proto messages
 func HelperGenerator(ctx *ezproto.Context, file *ezproto.File) error {
-	ctx.Debug("Processing file: %s", file.Name)
+	ctx.Debugf("Processing file: %s", file.Name)
 
 	code := ctx.Code().
 		Comment("Generated from " + file.Name).
@@ -0,0 +1,354 @@
+package ezproto
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// qualifier qualifies an identifier for the file an AST is rendered into.
+type qualifier func(ident protogen.GoIdent) string
+
+// Node is a typed code fragment, either an Expr or a Stmt.
+type Node interface {
+	node(q qualifier) ast.Node
+}
+
+// Expr is a Go expression built from typed values.
+//
+// Expressions are immutable values: they can be stored, combined and reused
+// across files, and identifiers created with Ref are qualified for the file
+// they are rendered into. Render them with CodeBuilder.Render, pass them as
+// format arguments to CodeBuilder.Line, or inspect them with CodeBuilder.AST.
+type Expr struct {
+	build func(q qualifier) ast.Expr
+}
+
+func (e Expr) node(q qualifier) ast.Node {
+	return e.ast(q)
+}
+
+func (e Expr) ast(q qualifier) ast.Expr {
+	if e.build == nil {
+		return ast.NewIdent("nil")
+	}
+
+	return e.build(q)
+}
+
+// exprs builds the ASTs of a list of expressions.
+func exprs(q qualifier, list []Expr) []ast.Expr {
+	nodes := make([]ast.Expr, len(list))
+	for i, e := range list {
+		nodes[i] = e.ast(q)
+	}
+
+	return nodes
+}
+
+// Id returns an unqualified identifier such as a local variable.
+func Id(name string) Expr {
+	return Expr{func(qualifier) ast.Expr { return ast.NewIdent(name) }}
+}
+
+// Ref returns a reference to an identifier that may live in another
+// package; see Ident.
+func Ref(ident protogen.GoIdent) Expr {
+	return Expr{func(q qualifier) ast.Expr {
+		pkg, name, ok := strings.Cut(q(ident), ".")
+		if !ok {
+			return ast.NewIdent(pkg)
+		}
+
+		return &ast.SelectorExpr{X: ast.NewIdent(pkg), Sel: ast.NewIdent(name)}
+	}}
+}
+
+// Raw returns an expression parsed from Go source, for code the typed layer
+// cannot express. Source that does not parse as an expression is rendered
+// verbatim and parenthesized when used as an operand.
+func Raw(src string) Expr {
+	if _, err := parser.ParseExpr(src); err != nil {
+		return Id(src)
+	}
+
+	// Parse on every build, so that transforming one AST leaves others intact
+	return Expr{func(qualifier) ast.Expr {
+		x, _ := parser.ParseExpr(src)
+
+		return x
+	}}
+}
+
+// ExprFromAST wraps a go/ast expression, e.g. one returned by
+// CodeBuilder.AST and transformed. The node is copied immediately, so later
+// changes to it have no effect.
+func ExprFromAST(x ast.Expr) Expr {
+	return Raw(render(x))
+}
+
+// Lit returns the Go literal for a string, bool, integer, float or nil value.
+// Infinities and NaN, which have no literal, become calls to math.Inf and
+// math.NaN. It panics for values of other kinds.
+func Lit(v any) Expr {
+	var (
+		kind token.Token
+		src  string
+	)
+
+	rv := reflect.ValueOf(v)
+
+	switch {
+	case v == nil:
+		return Id("nil")
+	case rv.Kind() == reflect.String:
+		kind, src = token.STRING, strconv.Quote(rv.String())
+	case rv.Kind() == reflect.Bool:
+		return Id(strconv.FormatBool(rv.Bool()))
+	case rv.CanInt():
+		kind, src = token.INT, strconv.FormatInt(rv.Int(), 10)
+	case rv.CanUint():
+		kind, src = token.INT, strconv.FormatUint(rv.Uint(), 10)
+	case rv.CanFloat() && (math.IsInf(rv.Float(), 0) || math.IsNaN(rv.Float())):
+		return nonFinite(rv.Float())
+	case rv.CanFloat():
+		kind, src = token.FLOAT, strconv.FormatFloat(rv.Float(), 'g', -1, rv.Type().Bits())
+		if !strings.ContainsAny(src, ".eEnN") {
+			src += ".0"
+		}
+	default:
+		panic(fmt.Sprintf("ezproto.Lit: unsupported literal type %T", v))
+	}
+
+	return Expr{func(qualifier) ast.Expr { return &ast.BasicLit{Kind: kind, Value: src} }}
+}
+
+// nonFinite returns the math call producing an infinity or NaN.
+func nonFinite(f float64) Expr {
+	switch {
+	case math.IsNaN(f):
+		return Call(Ref(Ident("math", "NaN")))
+	case f > 0:
+		return Call(Ref(Ident("math", "Inf")), Lit(1))
+	default:
+		return Call(Ref(Ident("math", "Inf")), Lit(-1))
+	}
+}
+
+// Call returns the call fn(args...).
+func Call(fn Expr, args ...Expr) Expr {
+	return Expr{func(q qualifier) ast.Expr {
+		return &ast.CallExpr{Fun: fn.ast(q), Args: exprs(q, args)}
+	}}
+}
+
+// Composite returns the composite literal typ{elts...}. Use KeyValue or
+// FieldValue for keyed elements.
+func Composite(typ Expr, elts ...Expr) Expr {
+	return Expr{func(q qualifier) ast.Expr {
+		return &ast.CompositeLit{Type: typ.ast(q), Elts: exprs(q, elts)}
+	}}
+}
+
+// KeyValue returns the composite literal element key: value.
+func KeyValue(key, value Expr) Expr {
+	return Expr{func(q qualifier) ast.Expr {
+		return &ast.KeyValueExpr{Key: key.ast(q), Value: value.ast(q)}
+	}}
+}
+
+// FieldValue returns the struct literal element name: value.
+func FieldValue(name string, value Expr) Expr {
+	return KeyValue(Id(name), value)
+}
+
+// SliceOf returns the slice type []elem.
+func SliceOf(elem Expr) Expr {
+	return Expr{func(q qualifier) ast.Expr { return &ast.ArrayType{Elt: elem.ast(q)} }}
+}
+
+// MapOf returns the map type map[key]value.
+func MapOf(key, value Expr) Expr {
+	return Expr{func(q qualifier) ast.Expr {
+		return &ast.MapType{Key: key.ast(q), Value: value.ast(q)}
+	}}
+}
+
+// Ptr returns the pointer type *elem.
+func Ptr(elem Expr) Expr {
+	return elem.Deref()
+}
+
+// Sel returns the selector e.name, chaining further names.
+func (e Expr) Sel(names ...string) Expr {
+	return Expr{func(q qualifier) ast.Expr {
+		x := e.ast(q)
+		for _, name := range names {
+			x = &ast.SelectorExpr{X: x, Sel: ast.NewIdent(name)}
+		}
+
+		return x
+	}}
+}
+
+// Call returns the call e(args...).
+func (e Expr) Call(args ...Expr) Expr {
+	return Call(e, args...)
+}
+
+// Index returns the index expression e[indices...], which is also used to
+// instantiate generic functions and types.
+func (e Expr) Index(indices ...Expr) Expr {
+	return Expr{func(q qualifier) ast.Expr {
+		if len(indices) == 1 {
+			return &ast.IndexExpr{X: e.ast(q), Index: indices[0].ast(q)}
+		}
+
+		return &ast.IndexListExpr{X: e.ast(q), Indices: exprs(q, indices)}
+	}}
+}
+
+// Slice returns the slice expression e[low:high]. A zero Expr omits a bound.
+func (e Expr) Slice(low, high Expr) Expr {
+	return Expr{func(q qualifier) ast.Expr {
+		s := &ast.SliceExpr{X: e.ast(q)}
+		if low.build != nil {
+			s.Low = low.ast(q)
+		}
+
+		if high.build != nil {
+			s.High = high.ast(q)
+		}
+
+		return s
+	}}
+}
+
+// Assert returns the type assertion e.(typ).
+func (e Expr) Assert(typ Expr) Expr {
+	return Expr{func(q qualifier) ast.Expr {
+		return &ast.TypeAssertExpr{X: e.ast(q), Type: typ.ast(q)}
+	}}
+}
+
+// Addr returns &e.
+func (e Expr) Addr() Expr {
+	return e.unary(token.AND)
+}
+
+// Deref returns *e, which is also the pointer type when e is a type.
+func (e Expr) Deref() Expr {
+	return Expr{func(q qualifier) ast.Expr { return &ast.StarExpr{X: e.ast(q)} }}
+}
+
+// Not returns !e.
+func (e Expr) Not() Expr {
+	return e.unary(token.NOT)
+}
+
+func (e Expr) unary(op token.Token) Expr {
+	return Expr{func(q qualifier) ast.Expr { return &ast.UnaryExpr{Op: op, X: e.ast(q)} }}
+}
+
+// Op returns the binary expression e op y, such as e.Op("==", Lit(0)).
+// Operands are parenthesized as needed to preserve the tree's structure.
+// It panics if op is not a Go binary operator.
+func (e Expr) Op(op string, y Expr) Expr {
+	tok, ok := binaryOperators[op]
+	if !ok {
+		panic(fmt.Sprintf("ezproto.Expr.Op: unknown binary operator %q", op))
+	}
+
+	return Expr{func(q qualifier) ast.Expr {
+		return &ast.BinaryExpr{
+			X:  parenthesize(e.ast(q), tok.Precedence(), false),
+			Op: tok,
+			Y:  parenthesize(y.ast(q), tok.Precedence(), true),
+		}
+	}}
+}
+
+// Paren returns (e).
+func (e Expr) Paren() Expr {
+	return Expr{func(q qualifier) ast.Expr { return &ast.ParenExpr{X: e.ast(q)} }}
+}
+
+// binaryOperators maps the spelling of Go's binary operators to their tokens.
+var binaryOperators = func() map[string]token.Token {
+	m := make(map[string]token.Token)
+
+	for _, tok := range []token.Token{
+		token.ADD, token.SUB, token.MUL, token.QUO, token.REM,
+		token.AND, token.OR, token.XOR, token.SHL, token.SHR, token.AND_NOT,
+		token.LAND, token.LOR,
+		token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ,
+	} {
+		m[tok.String()] = tok
+	}
+
+	return m
+}()
+
+// parenthesize wraps binary operands that bind less tightly than their
+// parent operator, so the printed code keeps the tree's meaning. Verbatim
+// source that could not be parsed is always wrapped.
+func parenthesize(x ast.Expr, prec int, right bool) ast.Expr {
+	switch x := x.(type) {
+	case *ast.BinaryExpr:
+		if p := x.Op.Precedence(); p < prec || (right && p == prec) {
+			return &ast.ParenExpr{X: x}
+		}
+	case *ast.Ident:
+		if !token.IsIdentifier(x.Name) && x.Name != "nil" {
+			return &ast.ParenExpr{X: x}
+		}
+	}
+
+	return x
+}
+
+// render prints an AST node the way gofmt does.
+func render(node ast.Node) string {
+	return renderIndented(node, 0)
+}
+
+// renderIndented prints node indented by indent tabs. The printer leaves
+// the content of multi-line raw strings untouched.
+func renderIndented(node ast.Node, indent int) string {
+	var buf bytes.Buffer
+
+	cfg := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8, Indent: indent}
+	if err := cfg.Fprint(&buf, token.NewFileSet(), node); err != nil {
+		// Only malformed hand-built ASTs fail to print
+		return fmt.Sprintf("/* %v */", err)
+	}
+
+	return buf.String()
+}
+
+// qualifier returns the qualifier for the builder's output file.
+func (cb *CodeBuilder) qualifier() qualifier {
+	return cb.Qualify
+}
+
+// Render returns the Go source of a typed expression or statement,
+// qualifying identifiers and importing their packages for the output file.
+func (cb *CodeBuilder) Render(n Node) string {
+	return render(n.node(cb.qualifier()))
+}
+
+// AST returns the go/ast form of a typed expression or statement, qualified
+// for the output file, so that it can be inspected or transformed. Wrap the
+// result with ExprFromAST or StmtFromAST to render it again.
+func (cb *CodeBuilder) AST(n Node) ast.Node {
+	return n.node(cb.qualifier())
+}
@@ -0,0 +1,148 @@
+package ezproto
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"strings"
+	"testing"
+)
+
+// renderFor runs fn with a CodeBuilder for internal/x.proto and returns the
+// generated file.
+func renderFor(t *testing.T, fn func(cb *CodeBuilder)) string {
+	t.Helper()
+
+	resp, err := NewPlugin().GenerateFor("internal/*", func(ctx *Context, _ *File) error {
+		cb := ctx.Code().Package("x")
+		fn(cb)
+		cb.Generate()
+
+		return nil
+	}).Generate(testRequest(""))
+	if err != nil || resp.Error != nil {
+		t.Fatalf("Generate() = %v, %v", resp.GetError(), err)
+	}
+
+	return resp.GetFile()[0].GetContent()
+}
+
+func TestExprRender(t *testing.T) {
+	mustParse := func(src string) ast.Expr {
+		x, err := parser.ParseExpr(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return x
+	}
+
+	tests := []struct {
+		name string
+		node Node
+		want string
+	}{
+		{"literals", Composite(Id("T"), Lit("a\"b"), Lit(true), Lit(-3), Lit(uint8(7)), Lit(1.5), Lit(2.0), Lit(nil)), `T{"a\"b", true, -3, 7, 1.5, 2.0, nil}`},
+		{"infinities", Composite(Id("T"), Lit(math.Inf(1)), Lit(float32(math.Inf(-1))), Lit(math.NaN())), "T{math.Inf(1), math.Inf(-1), math.NaN()}"},
+		{"qualified", Ref(Ident("time", "Duration")).Call(Lit(1)), "time.Duration(1)"},
+		{"types", MapOf(Id("string"), SliceOf(Ptr(Ref(Ident("time", "Time"))))), "map[string][]*time.Time"},
+		{"selectors", Id("x").Sel("a", "b").Index(Lit(0)).Slice(Expr{}, Lit(2)), "x.a.b[0][:2]"},
+		{"generic", Id("Map").Index(Id("K"), Id("V")), "Map[K, V]"},
+		{"unary", Id("x").Assert(Id("T")).Addr().Not(), "!&x.(T)"},
+		{"precedence", Id("a").Op("+", Id("b")).Op("*", Id("c")), "(a + b) * c"},
+		{"left associative", Id("a").Op("-", Id("b").Op("-", Id("c"))), "a - (b - c)"},
+		{"no extra parentheses", Id("a").Op("*", Id("b")).Op("+", Id("c")), "a*b + c"},
+		{"raw operand", Raw("a || b").Op("&&", Id("c")), "(a || b) && c"},
+		{"raw qualified", Raw("x.y[1]").Op("==", Lit(2)), "x.y[1] == 2"},
+		{"unparsable raw operand", Raw("x...").Op("&&", Id("c")), "(x...) && c"},
+		{"ast operand", ExprFromAST(mustParse("a + b")).Op("*", Id("c")), "(a + b) * c"},
+		{"keyed", Composite(Id("T"), FieldValue("A", Lit(1)), KeyValue(Lit("k"), Id("v"))), `T{A: 1, "k": v}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+
+			content := renderFor(t, func(cb *CodeBuilder) { got = cb.Render(tt.node) })
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+
+			// Qualified identifiers import their package
+			for _, pkg := range []string{"math", "time"} {
+				if strings.Contains(tt.want, pkg+".") != strings.Contains(content, `"`+pkg+`"`) {
+					t.Errorf("import of %s in\n%s", pkg, content)
+				}
+			}
+		})
+	}
+}
+
+func TestExprFromASTCopies(t *testing.T) {
+	x, err := parser.ParseExpr("a + b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := ExprFromAST(x)
+	x.(*ast.BinaryExpr).Op = token.SUB
+
+	renderFor(t, func(cb *CodeBuilder) {
+		// Transforming one AST must not change the expression
+		cb.AST(e).(*ast.BinaryExpr).Op = token.MUL
+
+		if got := cb.Render(e); got != "a + b" {
+			t.Errorf("Render(ExprFromAST) = %q, want %q", got, "a + b")
+		}
+	})
+}
+
+func TestStmtFromAST(t *testing.T) {
+	x, err := parser.ParseExpr("func() { if a { b() } else { c() } }")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt := StmtFromAST(x.(*ast.FuncLit).Body.List[0])
+
+	content := renderFor(t, func(cb *CodeBuilder) {
+		if _, ok := cb.AST(stmt).(*ast.IfStmt); !ok {
+			t.Errorf("AST(StmtFromAST(if)) = %T, want *ast.IfStmt", cb.AST(stmt))
+		}
+
+		cb.Function("F()", func(cb *CodeBuilder) { cb.Stmt(stmt) })
+	})
+
+	want := "func F() {\n\tif a {\n\t\tb()\n\t} else {\n\t\tc()\n\t}\n}\n"
+	if !strings.HasSuffix(content, want) {
+		t.Errorf("generated\n%s\nwant it to end with\n%s", content, want)
+	}
+}
+
+func TestOpUnknownOperator(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Op(\"=\") did not panic")
+		}
+	}()
+
+	Id("a").Op("=", Id("b"))
+}
+
+func TestStmtRawString(t *testing.T) {
+	content := renderFor(t, func(cb *CodeBuilder) {
+		cb.Function("F()", func(cb *CodeBuilder) {
+			cb.Stmt(IfStmt(Id("ok"),
+				DefineStmt([]string{"s"}, Raw("`first\n\tsecond\nthird`")),
+				ExprStmt(Id("use").Call(Id("s"))),
+			))
+		})
+	})
+
+	// Only the statement lines are indented, not the raw string content
+	want := "func F() {\n\tif ok {\n\t\ts := `first\n\tsecond\nthird`\n\t\tuse(s)\n\t}\n}\n"
+	if !strings.HasSuffix(content, want) {
+		t.Errorf("generated\n%s\nwant it to end with\n%s", content, want)
+	}
+}
@@ -7,8 +7,33 @@ import (
 
 // File represents a proto file being processed.
 type File struct {
-	proto *protogen.File
-	Name  string
+	proto      *protogen.File
+	Name       string
+	model      *model
+	messages   []*Message
+	services   []*Service
+	enums      []*Enum
+	extensions []*Extension
+}
+
+// FullName returns the path of this file, which is unique within a request.
+func (f *File) FullName() string {
+	return f.Name
+}
+
+// Parent returns nil, since a file is the root of the model.
+func (f *File) Parent() Element {
+	return nil
+}
+
+// File returns the file itself.
+func (f *File) File() *File {
+	return f
+}
+
+// ProtoPackage returns the protobuf package name declared in this file.
+func (f *File) ProtoPackage() string {
+	return string(f.proto.Desc.Package())
 }
 
 // Package returns the Go package name for this file.
@@ -21,62 +46,57 @@ func (f *File) GoImportPath() string {
 	return string(f.proto.GoImportPath)
 }
 
-// Messages returns all message types defined in this file.
+// Messages returns all top-level message types defined in this file.
 func (f *File) Messages() []*Message {
-	messages := make([]*Message, 0, len(f.proto.Messages))
-	for _, msg := range f.proto.Messages {
-		messages = append(messages, &Message{
-			proto: msg,
-			Name:  string(msg.Desc.Name()),
-		})
-	}
-
-	return messages
+	return f.messages
 }
 
 // Services returns all service types defined in this file.
 func (f *File) Services() []*Service {
-	services := make([]*Service, 0, len(f.proto.Services))
-	for _, svc := range f.proto.Services {
-		services = append(services, &Service{
-			proto: svc,
-			Name:  string(svc.Desc.Name()),
-		})
-	}
+	return f.services
+}
 
-	return services
+// Extensions returns all top-level extension declarations in this file.
+func (f *File) Extensions() []*Extension {
+	return f.extensions
 }
 
-// Enums returns all enum types defined in this file.
+// Enums returns all top-level enum types defined in this file.
 func (f *File) Enums() []*Enum {
-	enums := make([]*Enum, 0, len(f.proto.Enums))
-	for _, enum := range f.proto.Enums {
-		enums = append(enums, &Enum{
-			proto: enum,
-			Name:  string(enum.Desc.Name()),
-		})
-	}
-
-	return enums
+	return f.enums
 }
 
 // Message represents a protobuf message type.
 type Message struct {
-	proto *protogen.Message
-	Name  string
+	proto      *protogen.Message
+	Name       string
+	parent     Element
+	file       *File
+	fields     []*Field
+	oneofs     []*Oneof
+	messages   []*Message
+	enums      []*Enum
+	extensions []*Extension
+}
+
+// FullName returns the fully qualified protobuf name for this message.
+func (m *Message) FullName() string {
+	return string(m.proto.Desc.FullName())
+}
+
+// Parent returns the file or message in which this message is declared.
+func (m *Message) Parent() Element {
+	return m.parent
+}
+
+// File returns the file in which this message is declared.
+func (m *Message) File() *File {
+	return m.file
 }
 
 // Fields returns all fields defined in this message.
 func (m *Message) Fields() []*Field {
-	fields := make([]*Field, 0, len(m.proto.Fields))
-	for _, field := range m.proto.Fields {
-		fields = append(fields, &Field{
-			proto: field,
-			Name:  string(field.Desc.Name()),
-		})
-	}
-
-	return fields
+	return m.fields
 }
 
 // GoName returns the Go type name for this message.
@@ -86,21 +106,82 @@ func (m *Message) GoName() string {
 
 // Oneofs returns all oneof fields defined in this message.
 func (m *Message) Oneofs() []*Oneof {
-	oneofs := make([]*Oneof, 0, len(m.proto.Oneofs))
-	for _, oneof := range m.proto.Oneofs {
-		oneofs = append(oneofs, &Oneof{
-			proto: oneof,
-			Name:  string(oneof.Desc.Name()),
-		})
-	}
+	return m.oneofs
+}
+
+// Messages returns all message types nested in this message.
+func (m *Message) Messages() []*Message {
+	return m.messages
+}
+
+// Enums returns all enum types nested in this message.
+func (m *Message) Enums() []*Enum {
+	return m.enums
+}
+
+// IsMapEntry returns true if this message is a synthetic map entry.
+func (m *Message) IsMapEntry() bool {
+	return m.proto.Desc.IsMapEntry()
+}
+
+// ReservedRanges returns the reserved field number ranges of this message.
+func (m *Message) ReservedRanges() []Range {
+	return fieldRanges(m.proto.Desc.ReservedRanges())
+}
 
-	return oneofs
+// ReservedNames returns the reserved field names of this message.
+func (m *Message) ReservedNames() []string {
+	return names(m.proto.Desc.ReservedNames())
+}
+
+// IsReservedNumber returns true if the field number is reserved in this message.
+func (m *Message) IsReservedNumber(number int32) bool {
+	return m.proto.Desc.ReservedRanges().Has(protoreflect.FieldNumber(number))
+}
+
+// IsReservedName returns true if the field name is reserved in this message.
+func (m *Message) IsReservedName(name string) bool {
+	return m.proto.Desc.ReservedNames().Has(protoreflect.Name(name))
+}
+
+// ExtensionRanges returns the extension number ranges declared by this message.
+func (m *Message) ExtensionRanges() []Range {
+	return fieldRanges(m.proto.Desc.ExtensionRanges())
+}
+
+// Extensions returns all extension declarations nested in this message.
+func (m *Message) Extensions() []*Extension {
+	return m.extensions
 }
 
 // Field represents a field in a protobuf message.
 type Field struct {
-	proto *protogen.Field
-	Name  string
+	proto  *protogen.Field
+	Name   string
+	parent Element
+	file   *File
+	oneof  *Oneof
+}
+
+// FullName returns the fully qualified protobuf name for this field.
+// The field of an extension has the same name as the extension itself.
+func (f *Field) FullName() string {
+	return string(f.proto.Desc.FullName())
+}
+
+// Parent returns the message declaring this field, or the extension it describes.
+func (f *Field) Parent() Element {
+	return f.parent
+}
+
+// File returns the file in which this field is declared.
+func (f *Field) File() *File {
+	return f.file
+}
+
+// Oneof returns the oneof group containing this field, or nil.
+func (f *Field) Oneof() *Oneof {
+	return f.oneof
 }
 
 // GoName returns the Go field name.
@@ -108,6 +189,12 @@ func (f *Field) GoName() string {
 	return f.proto.GoName
 }
 
+// JSONName returns the JSON name of this field, which is the lowerCamelCase
+// form of its name unless set with the json_name option.
+func (f *Field) JSONName() string {
+	return f.proto.Desc.JSONName()
+}
+
 // GoType returns the Go type name for this field.
 func (f *Field) GoType() string {
 	return f.proto.GoIdent.GoName
@@ -153,21 +240,30 @@ func (f *Field) Type() string {
 
 // Service represents a protobuf service definition.
 type Service struct {
-	proto *protogen.Service
-	Name  string
+	proto   *protogen.Service
+	Name    string
+	file    *File
+	methods []*Method
+}
+
+// FullName returns the fully qualified protobuf name for this service.
+func (s *Service) FullName() string {
+	return string(s.proto.Desc.FullName())
+}
+
+// Parent returns the file in which this service is declared.
+func (s *Service) Parent() Element {
+	return s.file
+}
+
+// File returns the file in which this service is declared.
+func (s *Service) File() *File {
+	return s.file
 }
 
 // Methods returns all methods defined in this service.
 func (s *Service) Methods() []*Method {
-	methods := make([]*Method, 0, len(s.proto.Methods))
-	for _, method := range s.proto.Methods {
-		methods = append(methods, &Method{
-			proto: method,
-			Name:  string(method.Desc.Name()),
-		})
-	}
-
-	return methods
+	return s.methods
 }
 
 // GoName returns the Go type name for this service.
@@ -177,8 +273,29 @@ func (s *Service) GoName() string {
 
 // Method represents a method in a protobuf service.
 type Method struct {
-	proto *protogen.Method
-	Name  string
+	proto  *protogen.Method
+	Name   string
+	parent *Service
+}
+
+// FullName returns the fully qualified protobuf name for this method.
+func (m *Method) FullName() string {
+	return string(m.proto.Desc.FullName())
+}
+
+// Parent returns the service in which this method is declared.
+func (m *Method) Parent() Element {
+	return m.parent
+}
+
+// Service returns the service in which this method is declared.
+func (m *Method) Service() *Service {
+	return m.parent
+}
+
+// File returns the file in which this method is declared.
+func (m *Method) File() *File {
+	return m.parent.file
 }
 
 // GoName returns the Go method name.
@@ -208,21 +325,26 @@ func (m *Method) IsServerStreaming() bool {
 
 // Enum represents a protobuf enum definition.
 type Enum struct {
-	proto *protogen.Enum
-	Name  string
+	proto  *protogen.Enum
+	Name   string
+	parent Element
+	file   *File
+	values []*EnumValue
+}
+
+// Parent returns the file or message in which this enum is declared.
+func (e *Enum) Parent() Element {
+	return e.parent
+}
+
+// File returns the file in which this enum is declared.
+func (e *Enum) File() *File {
+	return e.file
 }
 
 // Values returns all values defined in this enum.
 func (e *Enum) Values() []*EnumValue {
-	values := make([]*EnumValue, 0, len(e.proto.Values))
-	for _, value := range e.proto.Values {
-		values = append(values, &EnumValue{
-			proto: value,
-			Name:  string(value.Desc.Name()),
-		})
-	}
-
-	return values
+	return e.values
 }
 
 // GoName returns the Go type name for this enum.
@@ -235,10 +357,59 @@ func (e *Enum) FullName() string {
 	return string(e.proto.Desc.FullName())
 }
 
+// ReservedRanges returns the reserved value number ranges of this enum.
+func (e *Enum) ReservedRanges() []Range {
+	ranges := e.proto.Desc.ReservedRanges()
+
+	result := make([]Range, 0, ranges.Len())
+	for i := range ranges.Len() {
+		r := ranges.Get(i)
+		result = append(result, Range{Start: int32(r[0]), End: int32(r[1])})
+	}
+
+	return result
+}
+
+// ReservedNames returns the reserved value names of this enum.
+func (e *Enum) ReservedNames() []string {
+	return names(e.proto.Desc.ReservedNames())
+}
+
+// IsReservedNumber returns true if the value number is reserved in this enum.
+func (e *Enum) IsReservedNumber(number int32) bool {
+	return e.proto.Desc.ReservedRanges().Has(protoreflect.EnumNumber(number))
+}
+
+// IsReservedName returns true if the value name is reserved in this enum.
+func (e *Enum) IsReservedName(name string) bool {
+	return e.proto.Desc.ReservedNames().Has(protoreflect.Name(name))
+}
+
 // EnumValue represents a value in a protobuf enum.
 type EnumValue struct {
-	proto *protogen.EnumValue
-	Name  string
+	proto  *protogen.EnumValue
+	Name   string
+	parent *Enum
+}
+
+// FullName returns the fully qualified protobuf name for this enum value.
+func (ev *EnumValue) FullName() string {
+	return string(ev.proto.Desc.FullName())
+}
+
+// Parent returns the enum in which this value is declared.
+func (ev *EnumValue) Parent() Element {
+	return ev.parent
+}
+
+// Enum returns the enum in which this value is declared.
+func (ev *EnumValue) Enum() *Enum {
+	return ev.parent
+}
+
+// File returns the file in which this enum value is declared.
+func (ev *EnumValue) File() *File {
+	return ev.parent.file
 }
 
 // GoName returns the Go constant name for this enum value.
@@ -253,8 +424,25 @@ func (ev *EnumValue) Number() int32 {
 
 // Oneof represents a protobuf oneof field group.
 type Oneof struct {
-	proto *protogen.Oneof
-	Name  string
+	proto  *protogen.Oneof
+	Name   string
+	parent *Message
+	fields []*Field
+}
+
+// FullName returns the fully qualified protobuf name for this oneof.
+func (o *Oneof) FullName() string {
+	return string(o.proto.Desc.FullName())
+}
+
+// Parent returns the message in which this oneof is declared.
+func (o *Oneof) Parent() Element {
+	return o.parent
+}
+
+// File returns the file in which this oneof is declared.
+func (o *Oneof) File() *File {
+	return o.parent.file
 }
 
 // GoName returns the Go field name for this oneof.
@@ -264,13 +452,82 @@ func (o *Oneof) GoName() string {
 
 // Fields returns all fields in this oneof group.
 func (o *Oneof) Fields() []*Field {
-	fields := make([]*Field, 0, len(o.proto.Fields))
-	for _, field := range o.proto.Fields {
-		fields = append(fields, &Field{
-			proto: field,
-			Name:  string(field.Desc.Name()),
-		})
+	return o.fields
+}
+
+// Extension represents a protobuf extension declaration.
+type Extension struct {
+	proto  *protogen.Extension
+	Name   string
+	parent Element
+	file   *File
+	field  *Field
+}
+
+// Parent returns the file or message in which this extension is declared.
+func (e *Extension) Parent() Element {
+	return e.parent
+}
+
+// File returns the file in which this extension is declared.
+func (e *Extension) File() *File {
+	return e.file
+}
+
+// GoName returns the Go name for this extension.
+// protoc-gen-go declares the extension variable as E_<GoName>.
+func (e *Extension) GoName() string {
+	return e.proto.GoIdent.GoName
+}
+
+// FullName returns the fully qualified protobuf name for this extension.
+func (e *Extension) FullName() string {
+	return string(e.proto.Desc.FullName())
+}
+
+// Number returns the field number of this extension.
+func (e *Extension) Number() int32 {
+	return int32(e.proto.Desc.Number())
+}
+
+// Extendee returns the fully qualified name of the message being extended.
+func (e *Extension) Extendee() string {
+	return string(e.proto.Desc.ContainingMessage().FullName())
+}
+
+// Field returns the extension as a field for type inspection.
+// The field shares the extension's FullName and has the extension as Parent.
+func (e *Extension) Field() *Field {
+	return e.field
+}
+
+// Range represents an inclusive range of field or enum value numbers.
+type Range struct {
+	Start int32
+	End   int32
+}
+
+// Contains returns true if the number falls within the range.
+func (r Range) Contains(number int32) bool {
+	return number >= r.Start && number <= r.End
+}
+
+// fieldRanges converts half-open field ranges into inclusive ranges.
+func fieldRanges(ranges protoreflect.FieldRanges) []Range {
+	result := make([]Range, 0, ranges.Len())
+	for i := range ranges.Len() {
+		r := ranges.Get(i)
+		result = append(result, Range{Start: int32(r[0]), End: int32(r[1]) - 1})
+	}
+
+	return result
+}
+
+func names(n protoreflect.Names) []string {
+	result := make([]string, 0, n.Len())
+	for i := range n.Len() {
+		result = append(result, string(n.Get(i)))
 	}
 
-	return fields
+	return result
 }
@@ -0,0 +1,84 @@
+package ezproto
+
+import (
+	"slices"
+	"testing"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestReservedRanges(t *testing.T) {
+	req := testRequest("")
+
+	// Message ranges are half-open in the descriptor, enum ranges inclusive
+	x := req.GetProtoFile()[4]
+	x.Syntax = proto.String("proto2")
+	x.MessageType[0].ReservedRange = []*descriptorpb.DescriptorProto_ReservedRange{{Start: proto.Int32(5), End: proto.Int32(10)}}
+	x.MessageType[0].ReservedName = []string{"old"}
+	x.MessageType[0].ExtensionRange = []*descriptorpb.DescriptorProto_ExtensionRange{{Start: proto.Int32(100), End: proto.Int32(200)}}
+	x.EnumType = []*descriptorpb.EnumDescriptorProto{testEnum("Kind")}
+	x.EnumType[0].ReservedRange = []*descriptorpb.EnumDescriptorProto_EnumReservedRange{{Start: proto.Int32(1), End: proto.Int32(3)}}
+	x.EnumType[0].ReservedName = []string{"KIND_OLD"}
+
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := newModel(gen).generated()[3]
+	msg, enum := file.Messages()[0], file.Enums()[0]
+
+	if got, want := msg.ReservedRanges(), []Range{{5, 9}}; !slices.Equal(got, want) {
+		t.Errorf("Message.ReservedRanges() = %v, want %v", got, want)
+	}
+
+	if got, want := msg.ExtensionRanges(), []Range{{100, 199}}; !slices.Equal(got, want) {
+		t.Errorf("Message.ExtensionRanges() = %v, want %v", got, want)
+	}
+
+	if got, want := enum.ReservedRanges(), []Range{{1, 3}}; !slices.Equal(got, want) {
+		t.Errorf("Enum.ReservedRanges() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		number    int32
+		msg, enum bool
+	}{
+		{0, false, false},
+		{1, false, true},
+		{3, false, true},
+		{4, false, false},
+		{5, true, false},
+		{9, true, false},
+		{10, false, false},
+	}
+
+	for _, tt := range tests {
+		if got := msg.IsReservedNumber(tt.number); got != tt.msg {
+			t.Errorf("Message.IsReservedNumber(%d) = %t, want %t", tt.number, got, tt.msg)
+		}
+
+		if got := enum.IsReservedNumber(tt.number); got != tt.enum {
+			t.Errorf("Enum.IsReservedNumber(%d) = %t, want %t", tt.number, got, tt.enum)
+		}
+
+		// Range.Contains agrees with the descriptor on both bounds
+		if got := msg.ReservedRanges()[0].Contains(tt.number); got != tt.msg {
+			t.Errorf("Message range Contains(%d) = %t, want %t", tt.number, got, tt.msg)
+		}
+
+		if got := enum.ReservedRanges()[0].Contains(tt.number); got != tt.enum {
+			t.Errorf("Enum range Contains(%d) = %t, want %t", tt.number, got, tt.enum)
+		}
+	}
+
+	if !msg.IsReservedName("old") || msg.IsReservedName("id") {
+		t.Errorf("Message.IsReservedName() does not match ReservedNames() = %q", msg.ReservedNames())
+	}
+
+	if !enum.IsReservedName("KIND_OLD") || enum.IsReservedName("Kind_UNSPECIFIED") {
+		t.Errorf("Enum.IsReservedName() does not match ReservedNames() = %q", enum.ReservedNames())
+	}
+}
@@ -0,0 +1,146 @@
+package ezproto
+
+import "fmt"
+
+// ForEachMessage registers a generator that runs for every message, including
+// nested messages, matching pattern. Synthetic map entry messages are skipped.
+// Code is written to the output of the file declaring the message.
+//
+// Element patterns are:
+//
+//	acme.orders.*         glob on the full name
+//	*Request              glob without '.' matches the short name
+//	re:^acme\.orders\.    regular expression on the full name
+//	option:deprecated     element option is set
+//	option:(acme.table)   element option, including extensions, is set
+//	file:api/**/*.proto   declaring file matches a file pattern
+//	!pattern              excludes elements matching pattern
+//
+// As in Select, '*' matches a single name component and "**" matches any
+// number of components.
+func (p *Plugin) ForEachMessage(pattern string, fn func(ctx *Context, msg *Message) error) *Plugin {
+	return p.forEach("messages", pattern, func(ctx *Context, file *File, match func(Element) bool) error {
+		return walkMessages(file.Messages(), func(msg *Message) error {
+			return visit(ctx, msg, match, fn)
+		})
+	})
+}
+
+// ForEachEnum registers a generator that runs for every enum, including enums
+// nested in messages, matching pattern. See ForEachMessage for the pattern syntax.
+func (p *Plugin) ForEachEnum(pattern string, fn func(ctx *Context, enum *Enum) error) *Plugin {
+	return p.forEach("enums", pattern, func(ctx *Context, file *File, match func(Element) bool) error {
+		for _, enum := range file.Enums() {
+			if err := visit(ctx, enum, match, fn); err != nil {
+				return err
+			}
+		}
+
+		return walkMessages(file.Messages(), func(msg *Message) error {
+			for _, enum := range msg.Enums() {
+				if err := visit(ctx, enum, match, fn); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+}
+
+// ForEachService registers a generator that runs for every service matching pattern.
+// See ForEachMessage for the pattern syntax.
+func (p *Plugin) ForEachService(pattern string, fn func(ctx *Context, svc *Service) error) *Plugin {
+	return p.forEach("services", pattern, func(ctx *Context, file *File, match func(Element) bool) error {
+		for _, svc := range file.Services() {
+			if err := visit(ctx, svc, match, fn); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// ForEachMethod registers a generator that runs for every service method matching pattern.
+// See ForEachMessage for the pattern syntax.
+func (p *Plugin) ForEachMethod(pattern string, fn func(ctx *Context, method *Method) error) *Plugin {
+	return p.forEach("methods", pattern, func(ctx *Context, file *File, match func(Element) bool) error {
+		for _, svc := range file.Services() {
+			for _, method := range svc.Methods() {
+				if err := visit(ctx, method, match, fn); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// forEach registers a file generator that walks elements matching pattern.
+func (p *Plugin) forEach(kind, pattern string, walk func(ctx *Context, file *File, match func(Element) bool) error) *Plugin {
+	match, err := selectElements(pattern)
+	if err != nil {
+		p.errs = append(p.errs, err)
+
+		return p
+	}
+
+	p.generators = append(p.generators, &registration{
+		name:     kind + " " + pattern,
+		selector: SelectorFunc(func(*File) bool { return true }),
+		generator: func(ctx *Context, file *File) error {
+			return walk(ctx, file, match)
+		},
+		// Middleware wraps each element rather than the walk
+		perElement: true,
+	})
+
+	return p
+}
+
+// visit runs fn for el if it matches, recording el as the current element
+// and wrapping the call in the plugin's middleware.
+func visit[T Element](ctx *Context, el T, match func(Element) bool, fn func(*Context, T) error) error {
+	if !match(el) {
+		return nil
+	}
+
+	// The element is deliberately not restored on panic, so that the
+	// recovered error can name it
+	prev := ctx.element
+	ctx.element = el
+
+	err := ctx.plugin.wrap(func(ctx *Context, _ *File) error {
+		return fn(ctx, el)
+	})(ctx, el.File())
+
+	ctx.element = prev
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", el.FullName(), err)
+	}
+
+	return nil
+}
+
+// walkMessages calls fn for every message and nested message, depth first,
+// skipping synthetic map entries.
+func walkMessages(msgs []*Message, fn func(*Message) error) error {
+	for _, msg := range msgs {
+		if msg.IsMapEntry() {
+			continue
+		}
+
+		if err := fn(msg); err != nil {
+			return err
+		}
+
+		if err := walkMessages(msg.Messages(), fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
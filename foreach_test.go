@@ -0,0 +1,151 @@
+package ezproto
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestForEach(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func(p *Plugin, record func(Element) error)
+		want     []string
+	}{
+		{
+			name: "all messages",
+			register: func(p *Plugin, record func(Element) error) {
+				p.ForEachMessage("**", func(_ *Context, msg *Message) error { return record(msg) })
+			},
+			want: []string{
+				"acme.orders.v1.Order", "acme.orders.v1.Order.Item", "acme.orders.v1.OrderRequest",
+				"acme.users.v2.User", "acme.users.v2.UserRequest", "internal.X",
+			},
+		},
+		{
+			name: "short name glob",
+			register: func(p *Plugin, record func(Element) error) {
+				p.ForEachMessage("*Request", func(_ *Context, msg *Message) error { return record(msg) })
+			},
+			want: []string{"acme.orders.v1.OrderRequest", "acme.users.v2.UserRequest"},
+		},
+		{
+			name: "full name glob",
+			register: func(p *Plugin, record func(Element) error) {
+				p.ForEachMessage("acme.*.*.*", func(_ *Context, msg *Message) error { return record(msg) })
+			},
+			want: []string{
+				"acme.orders.v1.Order", "acme.orders.v1.OrderRequest",
+				"acme.users.v2.User", "acme.users.v2.UserRequest",
+			},
+		},
+		{
+			name: "regexp",
+			register: func(p *Plugin, record func(Element) error) {
+				p.ForEachMessage(`re:\.Order\.`, func(_ *Context, msg *Message) error { return record(msg) })
+			},
+			want: []string{"acme.orders.v1.Order.Item"},
+		},
+		{
+			name: "custom option",
+			register: func(p *Plugin, record func(Element) error) {
+				p.ForEachMessage("option:(acme.table)", func(_ *Context, msg *Message) error { return record(msg) })
+			},
+			want: []string{"acme.orders.v1.Order"},
+		},
+		{
+			name: "exclude option",
+			register: func(p *Plugin, record func(Element) error) {
+				p.ForEachMessage("!option:deprecated", func(_ *Context, msg *Message) error { return record(msg) })
+			},
+			want: []string{
+				"acme.orders.v1.Order", "acme.orders.v1.Order.Item", "acme.orders.v1.OrderRequest",
+				"acme.users.v2.UserRequest", "internal.X",
+			},
+		},
+		{
+			name: "file pattern",
+			register: func(p *Plugin, record func(Element) error) {
+				p.ForEachMessage("file:internal/*", func(_ *Context, msg *Message) error { return record(msg) })
+			},
+			want: []string{"internal.X"},
+		},
+		{
+			name: "enums",
+			register: func(p *Plugin, record func(Element) error) {
+				p.ForEachEnum("**", func(_ *Context, enum *Enum) error { return record(enum) })
+			},
+			want: []string{"acme.orders.v1.Status", "acme.orders.v1.Order.State"},
+		},
+		{
+			name: "services and methods",
+			register: func(p *Plugin, record func(Element) error) {
+				p.ForEachService("**", func(_ *Context, svc *Service) error { return record(svc) }).
+					ForEachMethod("Get*", func(_ *Context, method *Method) error { return record(method) })
+			},
+			want: []string{"acme.orders.v1.OrderService", "acme.orders.v1.OrderService.GetOrder"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+
+			p := NewPlugin()
+			tt.register(p, func(el Element) error {
+				got = append(got, el.FullName())
+
+				return nil
+			})
+
+			resp, err := p.Generate(testRequest(""))
+			if err != nil || resp.Error != nil {
+				t.Fatalf("Generate() = %v, %v", resp.GetError(), err)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("visited %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForEachElementContext(t *testing.T) {
+	errStop := errors.New("stop")
+
+	var current []string
+
+	resp, err := NewPlugin().
+		ForEachMessage("*Request", func(ctx *Context, msg *Message) error {
+			current = append(current, ctx.Element().FullName())
+
+			if msg.File().Name == "api/v2/users.proto" {
+				return errStop
+			}
+
+			return nil
+		}).
+		Generate(testRequest(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"acme.orders.v1.OrderRequest", "acme.users.v2.UserRequest"}; !slices.Equal(current, want) {
+		t.Errorf("Element() = %q, want %q", current, want)
+	}
+
+	// Errors name the element being visited
+	if !strings.Contains(resp.GetError(), "acme.users.v2.UserRequest: stop") {
+		t.Errorf("Generate() error = %q, want it to name the element", resp.GetError())
+	}
+}
+
+func TestForEachInvalidPattern(t *testing.T) {
+	resp, err := NewPlugin().
+		ForEachMessage("option:(acme.table", func(*Context, *Message) error { return nil }).
+		Generate(testRequest(""))
+	if err != nil || !strings.Contains(resp.GetError(), ErrInvalidPattern.Error()) {
+		t.Errorf("Generate() = %q, %v, want an invalid pattern error", resp.GetError(), err)
+	}
+}
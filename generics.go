@@ -0,0 +1,127 @@
+package ezproto
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// TypeParam is a type parameter with its constraint, such as K comparable.
+// An empty constraint means any. Use CodeBuilder.Qualify for constraints
+// declared in other packages.
+type TypeParam struct {
+	Name       string
+	Constraint string
+}
+
+// TypeParams is a type parameter list.
+type TypeParams []TypeParam
+
+// String returns the declaration form of the list, such as
+// "[K comparable, V any]", or an empty string for an empty list.
+func (tps TypeParams) String() string {
+	if len(tps) == 0 {
+		return ""
+	}
+
+	parts := make([]string, len(tps))
+	for i, tp := range tps {
+		constraint := tp.Constraint
+		if constraint == "" {
+			constraint = "any"
+		}
+
+		parts[i] = tp.Name + " " + constraint
+	}
+
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// Names returns the list as type arguments, such as "[K, V]", for use in
+// receivers and self-references. It returns an empty string for an empty list.
+func (tps TypeParams) Names() string {
+	if len(tps) == 0 {
+		return ""
+	}
+
+	names := make([]string, len(tps))
+	for i, tp := range tps {
+		names[i] = tp.Name
+	}
+
+	return "[" + strings.Join(names, ", ") + "]"
+}
+
+// Instantiate returns the generic type or function name instantiated with
+// typeArgs, such as Set[int].
+func Instantiate(name string, typeArgs ...string) string {
+	if len(typeArgs) == 0 {
+		return name
+	}
+
+	return name + "[" + strings.Join(typeArgs, ", ") + "]"
+}
+
+// Instantiate returns a generic type or function from any package,
+// qualified for the output file and instantiated with typeArgs.
+func (cb *CodeBuilder) Instantiate(generic protogen.GoIdent, typeArgs ...string) string {
+	return Instantiate(cb.Qualify(generic), typeArgs...)
+}
+
+// Approx returns the type set term ~typ, for use with InterfaceBuilder.Union.
+func Approx(typ string) string {
+	return "~" + typ
+}
+
+// GenericStruct creates a generic struct type definition.
+func (cb *CodeBuilder) GenericStruct(name string, typeParams TypeParams, fn func(*StructBuilder)) *CodeBuilder {
+	sb := &StructBuilder{cb: cb}
+	cb.Line("type %s%s struct {", name, typeParams)
+
+	cb.indent++
+
+	fn(sb)
+
+	cb.indent--
+	cb.Line("}")
+
+	return cb
+}
+
+// GenericInterface creates a generic interface, typically a constraint.
+func (cb *CodeBuilder) GenericInterface(name string, typeParams TypeParams, fn func(*InterfaceBuilder)) *CodeBuilder {
+	ib := &InterfaceBuilder{cb: cb}
+	cb.Line("type %s%s interface {", name, typeParams)
+
+	cb.indent++
+
+	fn(ib)
+
+	cb.indent--
+	cb.Line("}")
+
+	return cb
+}
+
+// GenericFunction creates a generic function definition. The signature
+// holds the parameters and results, such as "(s []T) T".
+func (cb *CodeBuilder) GenericFunction(name string, typeParams TypeParams, signature string, fn func(*CodeBuilder)) *CodeBuilder {
+	return cb.Function(name+typeParams.String()+signature, fn)
+}
+
+// GenericMethod creates a method of a generic type. Go methods cannot
+// declare their own type parameters, so the receiver is written with the
+// type's parameter names, such as (s *Set[T]) for receiver "s", receiverType
+// "*Set" and a single type parameter T.
+func (cb *CodeBuilder) GenericMethod(receiver, receiverType string, typeParams TypeParams, name, params, returns string, fn func(*CodeBuilder)) *CodeBuilder {
+	return cb.Method(fmt.Sprintf("%s %s%s", receiver, receiverType, typeParams.Names()), name, params, returns, fn)
+}
+
+// Union adds a type set element to a constraint interface, such as
+// ~int | ~string.
+func (ib *InterfaceBuilder) Union(terms ...string) *InterfaceBuilder {
+	ib.cb.Line("%s", strings.Join(terms, " | "))
+
+	return ib
+}
@@ -0,0 +1,71 @@
+package ezproto
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTypeParams(t *testing.T) {
+	tests := []struct {
+		name      string
+		tps       TypeParams
+		wantDecl  string
+		wantNames string
+	}{
+		{"empty", nil, "", ""},
+		{"any", TypeParams{{Name: "T"}}, "[T any]", "[T]"},
+		{"constraints", TypeParams{{"K", "comparable"}, {"V", ""}}, "[K comparable, V any]", "[K, V]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.tps.String(); got != tt.wantDecl {
+				t.Errorf("String() = %q, want %q", got, tt.wantDecl)
+			}
+
+			if got := tt.tps.Names(); got != tt.wantNames {
+				t.Errorf("Names() = %q, want %q", got, tt.wantNames)
+			}
+		})
+	}
+
+	if got := Instantiate("Set"); got != "Set" {
+		t.Errorf("Instantiate(Set) = %q, want Set", got)
+	}
+
+	if got := Instantiate("Map", "string", Instantiate("Set", "int")); got != "Map[string, Set[int]]" {
+		t.Errorf("Instantiate() = %q, want %q", got, "Map[string, Set[int]]")
+	}
+}
+
+func TestGenerics(t *testing.T) {
+	content := renderFor(t, func(cb *CodeBuilder) {
+		tps := TypeParams{{"K", "comparable"}, {"V", ""}}
+
+		cb.GenericInterface("Number", nil, func(ib *InterfaceBuilder) { ib.Union(Approx("int"), Approx("float64")) })
+		cb.GenericInterface("Keyed", TypeParams{{"K", cb.Qualify(Ident("cmp", "Ordered"))}}, func(ib *InterfaceBuilder) { ib.Method("Key", "", "K") })
+		cb.GenericStruct("Cache", tps, func(sb *StructBuilder) { sb.Field("m", "map[K]V") })
+		cb.GenericMethod("c", "*Cache", tps, "Get", "k K", "(V, bool)", func(cb *CodeBuilder) {
+			cb.Line("v, ok := c.m[k]").Return("v", "ok")
+		})
+		cb.GenericFunction("Sum", TypeParams{{"T", "Number"}}, "(xs ...T) T", func(cb *CodeBuilder) {
+			cb.Line("var s T").Return("s")
+		})
+		cb.Var("c", cb.Instantiate(Ident("example.com/cache", "Cache"), "string", Instantiate("Cache", "int", "bool")))
+	})
+
+	// Constraints and generic types of other packages are imported
+	for _, want := range []string{
+		"\tcmp \"cmp\"\n\tcache \"example.com/cache\"\n",
+		"type Number interface {\n\t~int | ~float64\n}\n",
+		"type Keyed[K cmp.Ordered] interface {\n\tKey() K\n}\n",
+		"type Cache[K comparable, V any] struct {\n\tm map[K]V\n}\n",
+		"func (c *Cache[K, V]) Get(k K) (V, bool) {\n\tv, ok := c.m[k]\n\treturn v, ok\n}\n",
+		"func Sum[T Number](xs ...T) T {\n\tvar s T\n\treturn s\n}\n",
+		"var c cache.Cache[string, Cache[int, bool]]\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated\n%s\nwant it to contain\n%s", content, want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package ezproto
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// Middleware wraps a GeneratorFunc with cross-cutting behavior such as
+// timing, tracing or skipping files.
+type Middleware func(next GeneratorFunc) GeneratorFunc
+
+// hooks holds the lifecycle callbacks registered on a plugin.
+type hooks struct {
+	middleware []Middleware
+	onStart    []func(rc *RequestContext) error
+	beforeFile []func(ctx *Context, file *File) error
+	afterFile  []func(ctx *Context, file *File) error
+	onFinish   []func(rc *RequestContext, resp *pluginpb.CodeGeneratorResponse) error
+}
+
+// Use adds middleware wrapping every generator. Per-element generators such
+// as ForEachMessage are wrapped once per element, with the element available
+// through ctx.Element, so middleware can skip elements by their options.
+// The first middleware added is the outermost.
+func (p *Plugin) Use(middleware ...Middleware) *Plugin {
+	p.hooks.middleware = append(p.hooks.middleware, middleware...)
+
+	return p
+}
+
+// OnStart registers a hook that runs before any generator.
+// The CodeGeneratorRequest is available through rc.Request.
+func (p *Plugin) OnStart(hook func(rc *RequestContext) error) *Plugin {
+	p.hooks.onStart = append(p.hooks.onStart, hook)
+
+	return p
+}
+
+// BeforeFile registers a hook that runs before the generators of each file.
+func (p *Plugin) BeforeFile(hook func(ctx *Context, file *File) error) *Plugin {
+	p.hooks.beforeFile = append(p.hooks.beforeFile, hook)
+
+	return p
+}
+
+// AfterFile registers a hook that runs after the generators of each file.
+func (p *Plugin) AfterFile(hook func(ctx *Context, file *File) error) *Plugin {
+	p.hooks.afterFile = append(p.hooks.afterFile, hook)
+
+	return p
+}
+
+// OnFinish registers a hook that runs once the response has been assembled,
+// even if generation failed. resp.File lists the produced files and
+// resp.Error reports a failure. An error returned by the hook fails the run.
+func (p *Plugin) OnFinish(hook func(rc *RequestContext, resp *pluginpb.CodeGeneratorResponse) error) *Plugin {
+	p.hooks.onFinish = append(p.hooks.onFinish, hook)
+
+	return p
+}
+
+// wrap applies the registered middleware to a generator.
+func (p *Plugin) wrap(generator GeneratorFunc) GeneratorFunc {
+	for i := len(p.hooks.middleware) - 1; i >= 0; i-- {
+		generator = p.hooks.middleware[i](generator)
+	}
+
+	return generator
+}
+
+func (p *Plugin) runStartHooks(rc *RequestContext) error {
+	for _, hook := range p.hooks.onStart {
+		if err := hook(rc); err != nil {
+			return fmt.Errorf("start hook failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (p *Plugin) runFileHooks(hooks []func(*Context, *File) error, ctx *Context, file *File) error {
+	for _, hook := range hooks {
+		if err := hook(ctx, file); err != nil {
+			return fmt.Errorf("file hook failed for %s: %w", file.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (p *Plugin) runFinishHooks(rc *RequestContext, resp *pluginpb.CodeGeneratorResponse) error {
+	for _, hook := range p.hooks.onFinish {
+		if err := hook(rc, resp); err != nil {
+			return fmt.Errorf("finish hook failed: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package ezproto
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func TestHooks(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	tests := []struct {
+		name string
+		fail string
+		want []string
+	}{
+		{
+			name: "lifecycle",
+			want: []string{
+				"start p=1",
+				"before acme/options.proto", "after acme/options.proto",
+				"before api/v1/orders.proto", "a> api/v1/orders.proto", "b> api/v1/orders.proto", "gen api/v1/orders.proto", "b<", "a<", "after api/v1/orders.proto",
+				"before api/v2/users.proto", "a> acme.users.v2.User", "b> acme.users.v2.User", "msg User", "b<", "a<", "a> api/v2/users.proto", "b> api/v2/users.proto", "gen api/v2/users.proto", "b<", "a<", "after api/v2/users.proto",
+				"before internal/x.proto", "after internal/x.proto",
+				"finish 2 files",
+			},
+		},
+		{
+			name: "failing generator",
+			fail: "api/v1/orders.proto",
+			want: []string{
+				"start p=1",
+				"before acme/options.proto", "after acme/options.proto",
+				"before api/v1/orders.proto", "a> api/v1/orders.proto", "b> api/v1/orders.proto", "gen api/v1/orders.proto", "b<", "a<",
+				"finish generator failed for api/v1/orders.proto: boom",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+
+			record := func(tag string) Middleware {
+				return func(next GeneratorFunc) GeneratorFunc {
+					return func(ctx *Context, file *File) error {
+						if el := ctx.Element(); el != nil {
+							got = append(got, tag+"> "+el.FullName())
+						} else {
+							got = append(got, tag+"> "+file.Name)
+						}
+
+						err := next(ctx, file)
+						got = append(got, tag+"<")
+
+						return err
+					}
+				}
+			}
+
+			resp, err := NewPlugin().
+				Use(record("a"), record("b")).
+				OnStart(func(rc *RequestContext) error {
+					got = append(got, "start "+rc.Request().GetParameter())
+
+					return nil
+				}).
+				BeforeFile(func(_ *Context, file *File) error {
+					got = append(got, "before "+file.Name)
+
+					return nil
+				}).
+				AfterFile(func(_ *Context, file *File) error {
+					got = append(got, "after "+file.Name)
+
+					return nil
+				}).
+				OnFinish(func(_ *RequestContext, resp *pluginpb.CodeGeneratorResponse) error {
+					if resp.Error != nil {
+						got = append(got, "finish "+resp.GetError())
+					} else {
+						got = append(got, fmt.Sprintf("finish %d files", len(resp.GetFile())))
+					}
+
+					return nil
+				}).
+				ForEachMessage("acme.users.v2.User", func(_ *Context, msg *Message) error {
+					got = append(got, "msg "+msg.Name)
+
+					return nil
+				}).
+				GenerateFor("api/**", func(ctx *Context, file *File) error {
+					got = append(got, "gen "+file.Name)
+					ctx.Code().Package(file.Package()).Generate()
+
+					if file.Name == tt.fail {
+						return errBoom
+					}
+
+					return nil
+				}).
+				Generate(testRequest("p=1"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ran\n%q\nwant\n%q", got, tt.want)
+			}
+
+			if (tt.fail != "") != (resp.Error != nil) {
+				t.Errorf("Generate() error = %q", resp.GetError())
+			}
+		})
+	}
+}
+
+func TestHookErrors(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	tests := []struct {
+		name   string
+		plugin *Plugin
+		want   string
+	}{
+		{"start", NewPlugin().OnStart(func(*RequestContext) error { return errBoom }), "start hook failed: boom"},
+		{"before file", NewPlugin().BeforeFile(func(*Context, *File) error { return errBoom }), "file hook failed for acme/options.proto: boom"},
+		{"after file", NewPlugin().AfterFile(func(*Context, *File) error { return errBoom }), "file hook failed for acme/options.proto: boom"},
+		{"finish", NewPlugin().OnFinish(func(*RequestContext, *pluginpb.CodeGeneratorResponse) error { return errBoom }), "finish hook failed: boom"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tt.plugin.GenerateFor("**", func(*Context, *File) error { return nil }).Generate(testRequest(""))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := resp.GetError(); got != tt.want {
+				t.Errorf("Generate() error = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareSkipsElements(t *testing.T) {
+	var got []string
+
+	resp, err := NewPlugin().
+		Use(func(next GeneratorFunc) GeneratorFunc {
+			return func(ctx *Context, file *File) error {
+				// Skip messages marked with (acme.table)
+				if el := ctx.Element(); el != nil {
+					if _, ok := optionValue(el, "(acme.table)"); ok {
+						return nil
+					}
+				}
+
+				return next(ctx, file)
+			}
+		}).
+		ForEachMessage("acme.orders.v1.*", func(_ *Context, msg *Message) error {
+			got = append(got, msg.FullName())
+
+			return nil
+		}).
+		Generate(testRequest(""))
+	if err != nil || resp.Error != nil {
+		t.Fatalf("Generate() = %v, %v", resp.GetError(), err)
+	}
+
+	if want := []string{"acme.orders.v1.OrderRequest"}; !slices.Equal(got, want) {
+		t.Errorf("generated for %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,162 @@
+package ezproto
+
+import (
+	"fmt"
+	"go/token"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// Ident returns the Go identifier name declared in the package importPath.
+//
+// Identifiers passed as arguments to CodeBuilder.Line, or to any other
+// CodeBuilder method that takes format arguments, are qualified for the
+// output file and their package is imported automatically. They can be
+// formatted with %s, %v or %I:
+//
+//	cb.Line("var timeout %I = 5 * %I", ezproto.Ident("time", "Duration"), ezproto.Ident("time", "Second"))
+//
+// If two imported packages share a name, the later one is given an alias
+// such as time1.
+func Ident(importPath, name string) protogen.GoIdent {
+	return protogen.GoIdent{
+		GoName:       name,
+		GoImportPath: protogen.GoImportPath(importPath),
+	}
+}
+
+// Qualify returns ident qualified for the output file, importing its
+// package if needed. Use it to pass identifiers to methods that take plain
+// strings, such as StructBuilder.Field.
+func (cb *CodeBuilder) Qualify(ident protogen.GoIdent) string {
+	if cb.ctx.output == nil {
+		cb.ctx.createOutputFile()
+	}
+
+	return cb.ctx.output.QualifiedGoIdent(ident)
+}
+
+// qualifyArgs replaces identifier arguments with their qualified names and
+// typed expressions or statements with their source.
+func (cb *CodeBuilder) qualifyArgs(args []any) []any {
+	var qualified []any
+
+	for i, arg := range args {
+		var src string
+
+		switch arg := arg.(type) {
+		case protogen.GoIdent:
+			src = cb.Qualify(arg)
+		case Node:
+			src = cb.Render(arg)
+		default:
+			continue
+		}
+
+		if qualified == nil {
+			qualified = append([]any(nil), args...)
+		}
+
+		qualified[i] = qualifiedIdent(src)
+	}
+
+	if qualified == nil {
+		return args
+	}
+
+	return qualified
+}
+
+// qualifiedIdent is a qualified identifier. It formats as plain text for
+// every verb but %q, so that it can be used with %I as well as %s and %v.
+type qualifiedIdent string
+
+// Format implements fmt.Formatter.
+func (q qualifiedIdent) Format(f fmt.State, verb rune) {
+	if verb == 'q' {
+		fmt.Fprintf(f, "%q", string(q))
+
+		return
+	}
+
+	_, _ = io.WriteString(f, string(q))
+}
+
+// importNames assigns package names to import paths the way protogen does,
+// adding a numeric suffix when two paths share a name.
+type importNames struct {
+	byPath map[protogen.GoImportPath]string
+	used   map[string]bool
+	paths  []protogen.GoImportPath
+}
+
+// qualify returns ident qualified relative to the package self.
+func (n *importNames) qualify(self protogen.GoImportPath, ident protogen.GoIdent) string {
+	if ident.GoImportPath == self {
+		return ident.GoName
+	}
+
+	if name, ok := n.byPath[ident.GoImportPath]; ok {
+		return name + "." + ident.GoName
+	}
+
+	if n.byPath == nil {
+		n.byPath = make(map[protogen.GoImportPath]string)
+		n.used = make(map[string]bool)
+	}
+
+	name := packageName(ident.GoImportPath)
+	for i, orig := 1, name; n.used[name]; i++ {
+		name = orig + strconv.Itoa(i)
+	}
+
+	n.byPath[ident.GoImportPath] = name
+	n.used[name] = true
+	n.paths = append(n.paths, ident.GoImportPath)
+
+	return name + "." + ident.GoName
+}
+
+// importBlock renders the imports in the order they were first used.
+func (n *importNames) importBlock() string {
+	if len(n.paths) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("import (\n")
+
+	for _, p := range n.paths {
+		fmt.Fprintf(&sb, "\t%s %q\n", n.byPath[p], string(p))
+	}
+
+	sb.WriteString(")\n")
+
+	return sb.String()
+}
+
+// packageName derives the default package name of an import path, the way
+// protogen does.
+func packageName(importPath protogen.GoImportPath) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+
+		return '_'
+	}, path.Base(string(importPath)))
+
+	r, _ := utf8.DecodeRuneInString(name)
+	if token.Lookup(name).IsKeyword() || !unicode.IsLetter(r) {
+		return "_" + name
+	}
+
+	return name
+}
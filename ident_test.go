@@ -0,0 +1,70 @@
+package ezproto
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+func TestQualify(t *testing.T) {
+	content := renderFor(t, func(cb *CodeBuilder) {
+		cb.Line("var d %I = %s", Ident("time", "Duration"), Ident("time", "Second"))
+		cb.Line("var a %v", Ident("example.com/other/time", "Thing"))
+		cb.Line("var s = %q", Ident("example.com/other/time", "Thing"))
+		cb.Line("var c %s", cb.Qualify(Ident("context", "Context")))
+		cb.Line("var self %I", Ident("example.com/internal/x", "X"))
+	})
+
+	// A later package sharing a name gets a numeric alias
+	want := `package x
+
+import (
+	context "context"
+	time1 "example.com/other/time"
+	time "time"
+)
+
+var d time.Duration = time.Second
+var a time1.Thing
+var s = "time1.Thing"
+var c context.Context
+var self X
+`
+	if content != want {
+		t.Errorf("generated\n%s\nwant\n%s", content, want)
+	}
+}
+
+func TestImportNames(t *testing.T) {
+	var names importNames
+
+	tests := []struct {
+		ident protogen.GoIdent
+		want  string
+	}{
+		{Ident("a/time", "T"), "time.T"},
+		{Ident("time", "Now"), "time1.Now"},
+		{Ident("b/time", "U"), "time2.U"},
+		{Ident("a/time", "V"), "time.V"},
+		{Ident("me", "Self"), "Self"},
+		{Ident("example.com/go-kit", "K"), "go_kit.K"},
+		{Ident("example.com/v2", "V"), "v2.V"},
+		{Ident("example.com/type", "T"), "_type.T"},
+		{Ident("example.com/2d", "P"), "_2d.P"},
+	}
+
+	for _, tt := range tests {
+		if got := names.qualify("me", tt.ident); got != tt.want {
+			t.Errorf("qualify(%v) = %q, want %q", tt.ident, got, tt.want)
+		}
+	}
+
+	want := "import (\n\ttime \"a/time\"\n\ttime1 \"time\"\n\ttime2 \"b/time\"\n\tgo_kit \"example.com/go-kit\"\n\tv2 \"example.com/v2\"\n\t_type \"example.com/type\"\n\t_2d \"example.com/2d\"\n)\n"
+	if got := names.importBlock(); got != want {
+		t.Errorf("importBlock() = %q, want %q", got, want)
+	}
+
+	if got := (&importNames{}).importBlock(); got != "" {
+		t.Errorf("importBlock() without imports = %q, want empty", got)
+	}
+}
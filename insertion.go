@@ -0,0 +1,94 @@
+package ezproto
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// InsertInto returns a CodeBuilder whose code is inserted into filename at
+// the @@protoc_insertion_point(point) marker when Generate is called.
+//
+// The file is typically produced by another plugin in the same protoc run,
+// such as protoc-gen-go, and must appear earlier on the protoc command line.
+// Inserted code cannot add imports, so identifiers from packages other than
+// the current one are qualified by package name and must already be
+// imported by the target file.
+func (c *Context) InsertInto(filename, point string) *CodeBuilder {
+	ins := &insertion{
+		filename:   filename,
+		point:      point,
+		importPath: c.importPath,
+	}
+
+	if c.deferred != nil {
+		c.deferred.insertions = append(c.deferred.insertions, ins)
+	} else {
+		c.plugin.insertions = append(c.plugin.insertions, ins)
+	}
+
+	ctx := *c
+	ctx.output = ins
+
+	return ctx.NewCodeBuilder()
+}
+
+// InsertionPoint declares an insertion point that downstream plugins can
+// target with InsertInto or protoc's insertion point mechanism.
+func (cb *CodeBuilder) InsertionPoint(name string) *CodeBuilder {
+	return cb.Line("// @@protoc_insertion_point(%s)", name)
+}
+
+// insertion is code to be inserted into another file at an insertion point.
+// It is added to the response after all regular files.
+type insertion struct {
+	filename   string
+	point      string
+	importPath protogen.GoImportPath
+	imports    importNames
+	content    strings.Builder
+}
+
+// P prints a line like protogen.GeneratedFile.P.
+func (i *insertion) P(v ...any) {
+	for _, x := range v {
+		if ident, ok := x.(protogen.GoIdent); ok {
+			i.content.WriteString(i.QualifiedGoIdent(ident))
+		} else {
+			fmt.Fprint(&i.content, x)
+		}
+	}
+
+	i.content.WriteByte('\n')
+}
+
+// QualifiedGoIdent qualifies identifiers from other packages by their
+// package name.
+func (i *insertion) QualifiedGoIdent(ident protogen.GoIdent) string {
+	return i.imports.qualify(i.importPath, ident)
+}
+
+// file returns the response file carrying the insertion.
+func (i *insertion) file() *pluginpb.CodeGeneratorResponse_File {
+	return &pluginpb.CodeGeneratorResponse_File{
+		Name:           proto.String(i.filename),
+		InsertionPoint: proto.String(i.point),
+		Content:        proto.String(i.content.String()),
+	}
+}
+
+// appendInsertions adds the insertions of the run to a successful response.
+func (p *Plugin) appendInsertions(resp *pluginpb.CodeGeneratorResponse) {
+	if resp.Error == nil {
+		for _, ins := range p.insertions {
+			if ins.content.Len() > 0 {
+				resp.File = append(resp.File, ins.file())
+			}
+		}
+	}
+
+	p.insertions = nil
+}
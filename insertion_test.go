@@ -0,0 +1,139 @@
+package ezproto
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func TestInsertAt(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "top level",
+			file:    "package x\n// @@protoc_insertion_point(p)\n",
+			content: "var A = 1\n",
+			want:    "package x\nvar A = 1\n// @@protoc_insertion_point(p)\n",
+		},
+		{
+			name:    "indented",
+			file:    "func F() {\n\t// @@protoc_insertion_point(p)\n}\n",
+			content: "a()\n\nb()",
+			want:    "func F() {\n\ta()\n\n\tb()\n\t// @@protoc_insertion_point(p)\n}\n",
+		},
+		{
+			name:    "first of several points",
+			file:    "// @@protoc_insertion_point(p)\n// @@protoc_insertion_point(q)\n",
+			content: "a\n",
+			want:    "a\n// @@protoc_insertion_point(p)\n// @@protoc_insertion_point(q)\n",
+		},
+		{
+			name:    "missing marker",
+			file:    "package x\n// @@protoc_insertion_point(other)\n",
+			content: "a\n",
+			wantErr: `insertion point "p" not found`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := insertAt([]byte(tt.file), "p", []byte(tt.content))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("insertAt() error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("insertAt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInsertInto(t *testing.T) {
+	p := func(concurrency int) *Plugin {
+		return NewPlugin().WithOptions(Options{Concurrency: concurrency}).
+			GenerateFor("api/v1/*", func(ctx *Context, _ *File) error {
+				ctx.Code().Package("orders").
+					Block("func (*Order) Validate() error", func(cb *CodeBuilder) {
+						cb.InsertionPoint("validate")
+						cb.Line("return nil")
+					}).
+					Generate()
+
+				return nil
+			}).
+			GenerateFor("api/v2/*", func(ctx *Context, _ *File) error {
+				// Identifiers of the current package stay unqualified, others use the package name
+				cb := ctx.InsertInto("orders.pb.go", "validate")
+				cb.Line("_ = %s", cb.Qualify(Ident("example.com/api/v1/orders", "User")))
+				cb.Line("_ = %s", cb.Qualify(Ident("time", "Now")))
+				cb.Generate()
+				ctx.InsertInto("orders.pb.go", "missing").Generate()
+
+				return nil
+			})
+	}
+
+	resp, err := p(0).Generate(testRequest(samePackage))
+	if err != nil || resp.Error != nil {
+		t.Fatalf("Generate() = %v, %v", resp.GetError(), err)
+	}
+
+	// Empty insertions are dropped and insertions follow the regular files
+	files := resp.GetFile()
+	if len(files) != 2 || files[1].GetInsertionPoint() != "validate" {
+		t.Fatalf("Generate() files = %v, want orders.pb.go and one insertion", files)
+	}
+
+	parallel, err := p(4).Generate(testRequest(samePackage))
+	if err != nil || !proto.Equal(parallel, resp) {
+		t.Errorf("parallel Generate() = %v, %v, want %v", parallel, err, resp)
+	}
+
+	dir := t.TempDir()
+	if err := WriteResponse(resp, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "orders.pb.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "package orders\n\nfunc (*Order) Validate() error {\n\t_ = User\n\t_ = time.Now\n\t// @@protoc_insertion_point(validate)\n\treturn nil\n}\n"
+	if string(got) != want {
+		t.Errorf("orders.pb.go = %q, want %q", got, want)
+	}
+
+	// Insertions into a file missing from the response apply to the one on disk
+	only := &pluginpb.CodeGeneratorResponse{File: files[1:]}
+	if err := WriteResponse(only, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = os.ReadFile(filepath.Join(dir, "orders.pb.go"))
+	if want := strings.Replace(want, "\t// @@", "\t_ = User\n\t_ = time.Now\n\t// @@", 1); err != nil || string(got) != want {
+		t.Errorf("orders.pb.go = %q, %v, want %q", got, err, want)
+	}
+
+	if err := WriteResponse(only, t.TempDir()); err == nil {
+		t.Error("WriteResponse() succeeded without the target file")
+	}
+}
@@ -0,0 +1,52 @@
+package ezproto
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Quote returns s as an interpreted Go string literal, such as "a\"b".
+func Quote(s string) string {
+	return strconv.Quote(s)
+}
+
+// RawOrQuoted returns s as a raw string literal in backticks when that
+// preserves it exactly, which keeps multi-line text readable, and as an
+// interpreted string literal otherwise: raw strings cannot contain
+// backticks, carriage returns or invalid UTF-8.
+func RawOrQuoted(s string) string {
+	if strings.ContainsAny(s, "`\r") || !utf8.ValidString(s) {
+		return strconv.Quote(s)
+	}
+
+	return "`" + s + "`"
+}
+
+// Verbatim adds code exactly as given, without interpreting format verbs,
+// indenting each non-empty line to the current level. Use it instead of
+// Line for text that may contain %.
+func (cb *CodeBuilder) Verbatim(code string) *CodeBuilder {
+	indent := strings.Repeat("\t", cb.indent)
+
+	for _, line := range splitCommentLines(code) {
+		if line == "" {
+			cb.lines = append(cb.lines, "")
+		} else {
+			cb.lines = append(cb.lines, indent+line)
+		}
+	}
+
+	return cb
+}
+
+// splitCommentLines splits text into lines, accepting \n and \r\n endings.
+func splitCommentLines(text string) []string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+
+	return lines
+}
@@ -0,0 +1,49 @@
+package ezproto
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		in        string
+		quote     string
+		rawQuoted string
+	}{
+		{"plain", `"plain"`, "`plain`"},
+		{"a\"b", `"a\"b"`, "`a\"b`"},
+		{"multi\nline %s", `"multi\nline %s"`, "`multi\nline %s`"},
+		{"a`b", "\"a`b\"", "\"a`b\""},
+		{"cr\r\n", `"cr\r\n"`, `"cr\r\n"`},
+		{"\xff", `"\xff"`, `"\xff"`},
+		{"é", `"é"`, "`é`"},
+		{"", `""`, "``"},
+	}
+
+	for _, tt := range tests {
+		if got := Quote(tt.in); got != tt.quote {
+			t.Errorf("Quote(%q) = %s, want %s", tt.in, got, tt.quote)
+		}
+
+		if got := RawOrQuoted(tt.in); got != tt.rawQuoted {
+			t.Errorf("RawOrQuoted(%q) = %s, want %s", tt.in, got, tt.rawQuoted)
+		}
+	}
+}
+
+func TestCommentAndVerbatim(t *testing.T) {
+	content := renderFor(t, func(cb *CodeBuilder) {
+		cb.Comment("line one 100%d\r\n\nline three")
+		cb.Function("F()", func(cb *CodeBuilder) {
+			cb.Comment("inside\nthe body")
+			cb.Verbatim("println(\"50%\")\n\nprintln()")
+		})
+	})
+
+	// Lines are split on \n and \r\n, and % is written as is
+	want := "// line one 100%d\n//\n// line three\nfunc F() {\n\t// inside\n\t// the body\n\tprintln(\"50%\")\n\n\tprintln()\n}\n"
+	if !strings.HasSuffix(content, want) {
+		t.Errorf("generated\n%s\nwant it to end with\n%s", content, want)
+	}
+}
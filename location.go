@@ -0,0 +1,100 @@
+package ezproto
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Location describes where an element is declared in its proto source.
+// Lines and columns are one-based, matching //line directives and compiler
+// diagnostics. They are zero when protoc did not provide SourceCodeInfo.
+type Location struct {
+	SourceFile  string
+	Path        []int32
+	StartLine   int
+	StartColumn int
+	EndLine     int
+	EndColumn   int
+}
+
+// IsValid returns true if the location carries a source position.
+func (l Location) IsValid() bool {
+	return l.StartLine > 0
+}
+
+// String returns the location as "file:line:column", or just the file
+// if no position is known.
+func (l Location) String() string {
+	if !l.IsValid() {
+		return l.SourceFile
+	}
+
+	return fmt.Sprintf("%s:%d:%d", l.SourceFile, l.StartLine, l.StartColumn)
+}
+
+// location looks up the source location of a descriptor.
+func location(desc protoreflect.Descriptor) Location {
+	file := desc.ParentFile()
+	loc := Location{
+		SourceFile: file.Path(),
+	}
+
+	src := file.SourceLocations().ByDescriptor(desc)
+	if file.SourceLocations().Len() == 0 || (src.Path == nil && desc != protoreflect.Descriptor(file)) {
+		return loc
+	}
+
+	loc.Path = append([]int32(nil), src.Path...)
+	loc.StartLine = src.StartLine + 1
+	loc.StartColumn = src.StartColumn + 1
+	loc.EndLine = src.EndLine + 1
+	loc.EndColumn = src.EndColumn + 1
+
+	return loc
+}
+
+// Location returns where this file is declared.
+func (f *File) Location() Location {
+	return location(f.proto.Desc)
+}
+
+// Location returns where this message is declared.
+func (m *Message) Location() Location {
+	return location(m.proto.Desc)
+}
+
+// Location returns where this field is declared.
+func (f *Field) Location() Location {
+	return location(f.proto.Desc)
+}
+
+// Location returns where this oneof is declared.
+func (o *Oneof) Location() Location {
+	return location(o.proto.Desc)
+}
+
+// Location returns where this service is declared.
+func (s *Service) Location() Location {
+	return location(s.proto.Desc)
+}
+
+// Location returns where this method is declared.
+func (m *Method) Location() Location {
+	return location(m.proto.Desc)
+}
+
+// Location returns where this enum is declared.
+func (e *Enum) Location() Location {
+	return location(e.proto.Desc)
+}
+
+// Location returns where this enum value is declared.
+func (ev *EnumValue) Location() Location {
+	return location(ev.proto.Desc)
+}
+
+// Location returns where this extension is declared.
+func (e *Extension) Location() Location {
+	return location(e.proto.Desc)
+}
@@ -0,0 +1,78 @@
+package ezproto
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestLocation(t *testing.T) {
+	req := testRequest("")
+
+	// Spans are zero-based: start line, start column, end line, end column
+	req.GetProtoFile()[4].SourceCodeInfo = &descriptorpb.SourceCodeInfo{
+		Location: []*descriptorpb.SourceCodeInfo_Location{
+			{Path: []int32{}, Span: []int32{0, 0, 6, 0}},
+			{Path: []int32{4, 0}, Span: []int32{2, 0, 4, 1}},
+			{Path: []int32{4, 0, 2, 0}, Span: []int32{3, 2, 18}},
+		},
+	}
+
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := newModel(gen).generated()
+	x := files[3].Messages()[0]
+
+	tests := []struct {
+		name   string
+		el     Element
+		want   Location
+		string string
+	}{
+		{"file", files[3], Location{SourceFile: "internal/x.proto", Path: []int32{}, StartLine: 1, StartColumn: 1, EndLine: 7, EndColumn: 1}, "internal/x.proto:1:1"},
+		{"message", x, Location{SourceFile: "internal/x.proto", Path: []int32{4, 0}, StartLine: 3, StartColumn: 1, EndLine: 5, EndColumn: 2}, "internal/x.proto:3:1"},
+		{"single line span", x.Fields()[0], Location{SourceFile: "internal/x.proto", Path: []int32{4, 0, 2, 0}, StartLine: 4, StartColumn: 3, EndLine: 4, EndColumn: 19}, "internal/x.proto:4:3"},
+		{"no source info", files[2].Messages()[0], Location{SourceFile: "api/v2/users.proto"}, "api/v2/users.proto"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.el.Location()
+			if got.SourceFile != tt.want.SourceFile || !slices.Equal(got.Path, tt.want.Path) ||
+				got.StartLine != tt.want.StartLine || got.StartColumn != tt.want.StartColumn ||
+				got.EndLine != tt.want.EndLine || got.EndColumn != tt.want.EndColumn {
+				t.Errorf("Location() = %+v, want %+v", got, tt.want)
+			}
+
+			if want := tt.want.StartLine > 0; got.IsValid() != want {
+				t.Errorf("IsValid() = %t, want %t", got.IsValid(), want)
+			}
+
+			if s := got.String(); s != tt.string {
+				t.Errorf("String() = %q, want %q", s, tt.string)
+			}
+		})
+	}
+}
+
+func TestLineDirective(t *testing.T) {
+	content := renderFor(t, func(cb *CodeBuilder) {
+		cb.Block("func F()", func(cb *CodeBuilder) {
+			cb.LineDirective(Location{SourceFile: "internal/x.proto", StartLine: 3, StartColumn: 1})
+			cb.LineDirective(Location{SourceFile: "internal/x.proto"})
+			cb.Line("return")
+		})
+	})
+
+	// The directive starts the line and invalid locations add nothing
+	want := "func F() {\n//line internal/x.proto:3:1\n\treturn\n}\n"
+	if !strings.HasSuffix(content, want) {
+		t.Errorf("generated\n%s\nwant it to end with\n%s", content, want)
+	}
+}
@@ -0,0 +1,140 @@
+package ezproto
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Log attribute keys attached to records emitted through Context.Logger.
+const (
+	LogKeyFile      = "proto_file"
+	LogKeyGenerator = "generator"
+	LogKeyElement   = "element"
+)
+
+// WithLogHandler sets the slog handler used for plugin logs.
+// It takes precedence over the log_file and log_format parameters.
+func (p *Plugin) WithLogHandler(handler slog.Handler) *Plugin {
+	p.logHandler = handler
+
+	return p
+}
+
+// newLogger builds the logger for a run.
+//
+// Without a handler set by WithLogHandler, records are written as text to
+// stderr, or to the file named by the log_file parameter since protoc owns
+// the plugin's standard streams. The log_format parameter selects "text" or
+// "json", and log_level selects the minimum level. The level defaults to
+// debug when Options.Debug is set and to info otherwise.
+// The returned function closes the log file, if any.
+func (p *Plugin) newLogger(params parameters) (*slog.Logger, func(), error) {
+	if p.logHandler != nil {
+		return slog.New(p.logHandler), func() {}, nil
+	}
+
+	level := slog.LevelInfo
+	if p.options.Debug {
+		level = slog.LevelDebug
+	}
+
+	if values := params.values("log_level"); len(values) > 0 {
+		if err := level.UnmarshalText([]byte(values[len(values)-1])); err != nil {
+			return nil, nil, fmt.Errorf("invalid log_level: %w", err)
+		}
+	}
+
+	var (
+		w       io.Writer = os.Stderr
+		closeFn           = func() {}
+	)
+
+	if values := params.values("log_file"); len(values) > 0 {
+		f, err := os.OpenFile(values[len(values)-1], os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+
+		w = f
+		closeFn = func() {
+			_ = f.Close()
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	format := "text"
+	if values := params.values("log_format"); len(values) > 0 {
+		format = values[len(values)-1]
+	}
+
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), closeFn, nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), closeFn, nil
+	default:
+		closeFn()
+
+		return nil, nil, fmt.Errorf("invalid log_format %q: want text or json", format)
+	}
+}
+
+// log returns the logger of the current run, discarding records outside a run.
+func (p *Plugin) log() *slog.Logger {
+	if p.logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+
+	return p.logger
+}
+
+// Logger returns a logger whose records carry the proto file, generator and
+// element being processed.
+func (c *Context) Logger() *slog.Logger {
+	logger := c.plugin.log()
+
+	if c.file != nil {
+		logger = logger.With(LogKeyFile, c.file.Desc.Path())
+	}
+
+	if c.generator != "" {
+		logger = logger.With(LogKeyGenerator, c.generator)
+	}
+
+	if c.element != nil {
+		logger = logger.With(LogKeyElement, c.element.FullName())
+	}
+
+	return logger
+}
+
+// Logger returns the logger for whole-request generators and hooks.
+func (rc *RequestContext) Logger() *slog.Logger {
+	return rc.plugin.log()
+}
+
+// setupLogger creates the logger for the current run.
+func (p *Plugin) setupLogger(params parameters) error {
+	logger, closeFn, err := p.newLogger(params)
+	if err != nil {
+		return err
+	}
+
+	p.logger = logger
+	p.closeLog = closeFn
+
+	return nil
+}
+
+// resetLogger closes the log file of the current run, if any.
+func (p *Plugin) resetLogger() {
+	if p.closeLog != nil {
+		p.closeLog()
+	}
+
+	p.logger = nil
+	p.closeLog = nil
+}
@@ -0,0 +1,125 @@
+package ezproto
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogParameters(t *testing.T) {
+	tests := []struct {
+		name    string
+		param   string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "text",
+			want:    []string{`level=INFO msg=hi proto_file=internal/x.proto generator=internal/*`},
+			notWant: []string{"running generator"},
+		},
+		{
+			name:  "json",
+			param: "log_format=json",
+			want:  []string{`"level":"INFO","msg":"hi","proto_file":"internal/x.proto","generator":"internal/*"`},
+		},
+		{
+			name:  "debug",
+			param: "debug",
+			want:  []string{"level=DEBUG msg=\"running generator\"", "level=INFO msg=hi"},
+		},
+		{
+			name:    "level",
+			param:   "debug,log_level=warn",
+			want:    []string{"level=WARN msg=careful"},
+			notWant: []string{"running generator", "msg=hi"},
+		},
+		{
+			name:  "last value wins",
+			param: "log_format=json,log_format=text",
+			want:  []string{"level=INFO msg=hi"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "gen.log")
+
+			resp, err := NewPlugin().
+				GenerateFor("internal/*", func(ctx *Context, _ *File) error {
+					ctx.Logger().Info("hi")
+					ctx.Logger().Warn("careful")
+
+					return nil
+				}).
+				Generate(testRequest(strings.TrimPrefix(tt.param+",log_file="+path, ",")))
+			if err != nil || resp.Error != nil {
+				t.Fatalf("Generate() = %v, %v", resp.GetError(), err)
+			}
+
+			b, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(string(b), want) {
+					t.Errorf("log\n%s\nwant it to contain %s", b, want)
+				}
+			}
+
+			for _, notWant := range tt.notWant {
+				if strings.Contains(string(b), notWant) {
+					t.Errorf("log\n%s\nwant it not to contain %s", b, notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestLogParameterErrors(t *testing.T) {
+	tests := []struct {
+		param string
+		want  string
+	}{
+		{"log_format=xml", `invalid log_format "xml": want text or json`},
+		{"log_level=loud", `invalid log_level: slog: level string "loud": unknown name`},
+		{"log_file=" + filepath.Join("missing", "dir", "gen.log"), "failed to open log file"},
+	}
+
+	for _, tt := range tests {
+		resp, err := NewPlugin().Generate(testRequest(tt.param))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := resp.GetError(); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("Generate(%q) error = %q, want %q", tt.param, got, tt.want)
+		}
+	}
+}
+
+func TestWithLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	// The handler takes precedence over the log parameters
+	resp, err := NewPlugin().
+		WithLogHandler(slog.NewTextHandler(&buf, nil)).
+		ForEachMessage("internal.X", func(ctx *Context, _ *Message) error {
+			ctx.Logger().Info("hi")
+
+			return nil
+		}).
+		Generate(testRequest("log_format=json,log_file=" + filepath.Join(t.TempDir(), "gen.log")))
+	if err != nil || resp.Error != nil {
+		t.Fatalf("Generate() = %v, %v", resp.GetError(), err)
+	}
+
+	want := `msg=hi proto_file=internal/x.proto generator="messages internal.X" element=internal.X`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("log\n%s\nwant it to contain %s", buf.String(), want)
+	}
+}
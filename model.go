@@ -0,0 +1,271 @@
+package ezproto
+
+import (
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Element is implemented by every type of the ezproto model.
+// Elements are built once per request, so pointers are stable and
+// can be used as map keys.
+type Element interface {
+	// FullName returns a name that identifies the element within the request.
+	// Names are unique, except that an Extension and its Field share one.
+	FullName() string
+	// Parent returns the enclosing element, or nil for a File.
+	Parent() Element
+	// File returns the file in which the element is declared.
+	File() *File
+	// Location returns where the element is declared in its proto source.
+	Location() Location
+}
+
+// model holds the ezproto representation of all files in a request.
+type model struct {
+	files  []*File
+	byPath map[string]*File
+	types  *protoregistry.Types
+}
+
+// newModel builds the ezproto model for every file known to the plugin.
+func newModel(gen *protogen.Plugin) *model {
+	m := &model{
+		files:  make([]*File, 0, len(gen.Files)),
+		byPath: make(map[string]*File, len(gen.Files)),
+		types:  new(protoregistry.Types),
+	}
+
+	for _, f := range gen.Files {
+		file := newFile(f, m)
+		m.files = append(m.files, file)
+		m.byPath[file.Name] = file
+
+		m.registerExtensions(f.Desc.Extensions(), f.Desc.Messages())
+	}
+
+	return m
+}
+
+// registerExtensions makes every extension declared in the request resolvable,
+// so that custom options can be read even if their Go types are not linked in.
+func (m *model) registerExtensions(exts protoreflect.ExtensionDescriptors, msgs protoreflect.MessageDescriptors) {
+	for i := range exts.Len() {
+		// Duplicate registrations are harmless, the first one wins
+		_ = m.types.RegisterExtension(dynamicpb.NewExtensionType(exts.Get(i)))
+	}
+
+	for i := range msgs.Len() {
+		msg := msgs.Get(i)
+		m.registerExtensions(msg.Extensions(), msg.Messages())
+	}
+}
+
+// options re-parses an options message so that custom options declared in the
+// request are populated instead of being kept as unknown fields.
+func (m *model) options(opts proto.Message) protoreflect.Message {
+	if opts == nil || !opts.ProtoReflect().IsValid() {
+		return nil
+	}
+
+	b, err := proto.Marshal(opts)
+	if err != nil {
+		return opts.ProtoReflect()
+	}
+
+	resolved := opts.ProtoReflect().New()
+	if err := (proto.UnmarshalOptions{Resolver: m.types}).Unmarshal(b, resolved.Interface()); err != nil {
+		return opts.ProtoReflect()
+	}
+
+	return resolved
+}
+
+// generated returns the files that protoc asked to generate.
+func (m *model) generated() []*File {
+	var files []*File
+
+	for _, f := range m.files {
+		if f.proto.Generate {
+			files = append(files, f)
+		}
+	}
+
+	return files
+}
+
+func newFile(f *protogen.File, m *model) *File {
+	file := &File{
+		proto: f,
+		Name:  f.Desc.Path(),
+		model: m,
+	}
+
+	file.messages = make([]*Message, 0, len(f.Messages))
+	for _, msg := range f.Messages {
+		file.messages = append(file.messages, newMessage(msg, file, file))
+	}
+
+	file.enums = make([]*Enum, 0, len(f.Enums))
+	for _, enum := range f.Enums {
+		file.enums = append(file.enums, newEnum(enum, file, file))
+	}
+
+	file.services = make([]*Service, 0, len(f.Services))
+	for _, svc := range f.Services {
+		file.services = append(file.services, newService(svc, file))
+	}
+
+	file.extensions = newExtensions(f.Extensions, file, file)
+
+	return file
+}
+
+func newMessage(msg *protogen.Message, parent Element, file *File) *Message {
+	m := &Message{
+		proto:  msg,
+		Name:   string(msg.Desc.Name()),
+		parent: parent,
+		file:   file,
+	}
+
+	m.fields = make([]*Field, 0, len(msg.Fields))
+	for _, field := range msg.Fields {
+		m.fields = append(m.fields, newField(field, m, file))
+	}
+
+	m.oneofs = make([]*Oneof, 0, len(msg.Oneofs))
+	for _, oneof := range msg.Oneofs {
+		o := &Oneof{
+			proto:  oneof,
+			Name:   string(oneof.Desc.Name()),
+			parent: m,
+		}
+
+		for _, field := range m.fields {
+			if field.proto.Oneof == oneof {
+				field.oneof = o
+				o.fields = append(o.fields, field)
+			}
+		}
+
+		m.oneofs = append(m.oneofs, o)
+	}
+
+	m.messages = make([]*Message, 0, len(msg.Messages))
+	for _, nested := range msg.Messages {
+		m.messages = append(m.messages, newMessage(nested, m, file))
+	}
+
+	m.enums = make([]*Enum, 0, len(msg.Enums))
+	for _, enum := range msg.Enums {
+		m.enums = append(m.enums, newEnum(enum, m, file))
+	}
+
+	m.extensions = newExtensions(msg.Extensions, m, file)
+
+	return m
+}
+
+func newField(field *protogen.Field, parent Element, file *File) *Field {
+	return &Field{
+		proto:  field,
+		Name:   string(field.Desc.Name()),
+		parent: parent,
+		file:   file,
+	}
+}
+
+func newEnum(enum *protogen.Enum, parent Element, file *File) *Enum {
+	e := &Enum{
+		proto:  enum,
+		Name:   string(enum.Desc.Name()),
+		parent: parent,
+		file:   file,
+	}
+
+	e.values = make([]*EnumValue, 0, len(enum.Values))
+	for _, value := range enum.Values {
+		e.values = append(e.values, &EnumValue{
+			proto:  value,
+			Name:   string(value.Desc.Name()),
+			parent: e,
+		})
+	}
+
+	return e
+}
+
+func newService(svc *protogen.Service, file *File) *Service {
+	s := &Service{
+		proto: svc,
+		Name:  string(svc.Desc.Name()),
+		file:  file,
+	}
+
+	s.methods = make([]*Method, 0, len(svc.Methods))
+	for _, method := range svc.Methods {
+		s.methods = append(s.methods, &Method{
+			proto:  method,
+			Name:   string(method.Desc.Name()),
+			parent: s,
+		})
+	}
+
+	return s
+}
+
+func newExtensions(exts []*protogen.Extension, parent Element, file *File) []*Extension {
+	extensions := make([]*Extension, 0, len(exts))
+	for _, ext := range exts {
+		e := &Extension{
+			proto:  ext,
+			Name:   string(ext.Desc.Name()),
+			parent: parent,
+			file:   file,
+		}
+		e.field = newField(ext, e, file)
+
+		extensions = append(extensions, e)
+	}
+
+	return extensions
+}
+
+// descriptor returns the protoreflect descriptor behind an element.
+func descriptor(el Element) protoreflect.Descriptor {
+	switch el := el.(type) {
+	case *File:
+		return el.proto.Desc
+	case *Message:
+		return el.proto.Desc
+	case *Field:
+		return el.proto.Desc
+	case *Oneof:
+		return el.proto.Desc
+	case *Service:
+		return el.proto.Desc
+	case *Method:
+		return el.proto.Desc
+	case *Enum:
+		return el.proto.Desc
+	case *EnumValue:
+		return el.proto.Desc
+	case *Extension:
+		return el.proto.Desc
+	default:
+		return nil
+	}
+}
+
+// elementOptions returns the options of an element with custom options resolved.
+func elementOptions(el Element) protoreflect.Message {
+	desc := descriptor(el)
+	if desc == nil {
+		return nil
+	}
+
+	return el.File().model.options(desc.Options())
+}
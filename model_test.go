@@ -0,0 +1,103 @@
+package ezproto
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestElementsAreShared(t *testing.T) {
+	var seen [2][]*File
+
+	p := NewPlugin()
+	for i := range seen {
+		p.GenerateFor("**", func(_ *Context, file *File) error {
+			seen[i] = append(seen[i], file)
+
+			return nil
+		})
+	}
+
+	resp, err := p.Generate(testOneofRequest(""))
+	if err != nil || resp.Error != nil {
+		t.Fatalf("Generate() = %v, %v", resp.GetError(), err)
+	}
+
+	if len(seen[0]) != 4 || len(seen[1]) != 4 {
+		t.Fatalf("generators saw %d and %d files, want 4", len(seen[0]), len(seen[1]))
+	}
+
+	// Every generator gets the same elements, so they work as map keys
+	for i, f := range seen[0] {
+		if seen[1][i] != f || !slices.Equal(seen[1][i].Messages(), f.Messages()) {
+			t.Errorf("generators got different elements for %s", f.Name)
+		}
+	}
+}
+
+func TestElementParents(t *testing.T) {
+	files := testModel(t)
+	options, orders := files[0], files[1]
+
+	order := orders.Messages()[0]
+	service := orders.Services()[0]
+	ext := options.Extensions()[0]
+
+	tests := []struct {
+		el       Element
+		fullName string
+		parent   Element
+		file     *File
+	}{
+		{orders, "api/v1/orders.proto", nil, orders},
+		{order, "acme.orders.v1.Order", orders, orders},
+		{order.Fields()[0], "acme.orders.v1.Order.id", order, orders},
+		{order.Messages()[0], "acme.orders.v1.Order.Item", order, orders},
+		{order.Enums()[0], "acme.orders.v1.Order.State", order, orders},
+		{order.Enums()[0].Values()[0], "acme.orders.v1.Order.State_UNSPECIFIED", order.Enums()[0], orders},
+		{orders.Enums()[0], "acme.orders.v1.Status", orders, orders},
+		{service, "acme.orders.v1.OrderService", orders, orders},
+		{service.Methods()[0], "acme.orders.v1.OrderService.GetOrder", service, orders},
+		{ext, "acme.gen", options, options},
+		{ext.Field(), "acme.gen", ext, options},
+	}
+
+	for _, tt := range tests {
+		if got := tt.el.FullName(); got != tt.fullName {
+			t.Errorf("FullName() = %q, want %q", got, tt.fullName)
+		}
+
+		if got := tt.el.Parent(); got != tt.parent {
+			t.Errorf("%s: Parent() = %v, want %v", tt.fullName, got, tt.parent)
+		}
+
+		if got := tt.el.File(); got != tt.file {
+			t.Errorf("%s: File() = %s, want %s", tt.fullName, got.Name, tt.file.Name)
+		}
+	}
+}
+
+func TestOneofParents(t *testing.T) {
+	var msg *Message
+
+	resp, err := NewPlugin().
+		ForEachMessage("internal.X", func(_ *Context, m *Message) error {
+			msg = m
+
+			return nil
+		}).
+		Generate(testOneofRequest(""))
+	if err != nil || resp.Error != nil {
+		t.Fatalf("Generate() = %v, %v", resp.GetError(), err)
+	}
+
+	oneof := msg.Oneofs()[0]
+	field := msg.Fields()[0]
+
+	if oneof.Parent() != msg || field.Parent() != msg {
+		t.Errorf("oneof and field parents = %v, %v, want %s", oneof.Parent(), field.Parent(), msg.FullName())
+	}
+
+	if field.Oneof() != oneof || oneof.Fields()[0] != field {
+		t.Errorf("oneof fields = %v, want %s", oneof.Fields(), field.FullName())
+	}
+}
@@ -0,0 +1,206 @@
+package ezproto
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// pluginPrefix is the executable name prefix protoc uses to find plugins.
+const pluginPrefix = "protoc-gen-"
+
+// Mux bundles several plugins into a single binary.
+//
+// A request is dispatched to the plugin named by the plugin parameter, or
+// else to the plugin named by the executable, so that a symlink called
+// protoc-gen-acme-sql runs the plugin registered as "acme-sql".
+type Mux struct {
+	plugins map[string]*Plugin
+	errs    []error
+}
+
+// NewMux creates an empty Mux.
+func NewMux() *Mux {
+	return &Mux{
+		plugins: make(map[string]*Plugin),
+	}
+}
+
+// Handle registers a plugin under name and returns the mux for chaining.
+// The name is the plugin's executable name without the protoc-gen- prefix.
+func (m *Mux) Handle(name string, plugin *Plugin) *Mux {
+	switch {
+	case name == "" || strings.ContainsAny(name, `/\`):
+		m.errs = append(m.errs, fmt.Errorf("invalid plugin name %q", name))
+	case m.plugins[name] != nil:
+		m.errs = append(m.errs, fmt.Errorf("plugin %q registered twice", name))
+	default:
+		m.plugins[name] = plugin
+	}
+
+	return m
+}
+
+// Plugins returns the names of the registered plugins in sorted order.
+func (m *Mux) Plugins() []string {
+	names := make([]string, 0, len(m.plugins))
+	for name := range m.plugins {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// Run dispatches the request read from stdin to the selected plugin.
+//
+// When run directly rather than by protoc, the binary accepts these commands:
+// "list" prints the executable name of every plugin, "install <dir>"
+// creates a protoc-gen-<name> symlink to the binary for each of them, and
+// "gen" and "replay" work like they do for Plugin.Run.
+func (m *Mux) Run() error {
+	if err := m.run(os.Args, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", filepath.Base(os.Args[0]), err)
+		os.Exit(1)
+	}
+
+	return nil
+}
+
+func (m *Mux) run(args []string, r io.Reader, w io.Writer) error {
+	executable := m.executablePlugin(args[0])
+	generate := func(req *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+		return m.dispatch(req, executable)
+	}
+
+	if len(args) > 1 {
+		return m.command(args[1:], w, generate)
+	}
+
+	return serve(r, w, generate)
+}
+
+// command runs a subcommand given on the command line.
+func (m *Mux) command(args []string, w io.Writer, generate generateFunc) error {
+	switch args[0] {
+	case "list":
+		for _, name := range m.Plugins() {
+			fmt.Fprintln(w, pluginPrefix+name)
+		}
+
+		return nil
+	case "install":
+		if len(args) != 2 {
+			return errors.New("usage: install <dir>")
+		}
+
+		return m.Install(args[1])
+	case "gen":
+		return runGen(args[1:], generate)
+	case "replay":
+		return runReplay(args[1:], w, generate)
+	default:
+		return fmt.Errorf("unknown argument %q (valid commands: list, install, gen, replay)", args[0])
+	}
+}
+
+// Generate dispatches a request to the plugin named by the plugin parameter.
+func (m *Mux) Generate(req *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+	return m.dispatch(req, "")
+}
+
+// dispatch runs the plugin selected by the plugin parameter, falling back to
+// the plugin named by the executable.
+func (m *Mux) dispatch(req *pluginpb.CodeGeneratorRequest, executable string) (*pluginpb.CodeGeneratorResponse, error) {
+	if err := errors.Join(m.errs...); err != nil {
+		return errorResponse(err), nil
+	}
+
+	params, err := parseParameters(req.GetParameter())
+	if err != nil {
+		return errorResponse(err), nil
+	}
+
+	name := executable
+	if values := params.values("plugin"); len(values) > 0 {
+		name = values[len(values)-1]
+	}
+
+	if name == "" {
+		return errorResponse(fmt.Errorf("no plugin selected: set plugin=<name> (available: %s)",
+			strings.Join(m.Plugins(), ", "))), nil
+	}
+
+	plugin, ok := m.plugins[name]
+	if !ok {
+		return errorResponse(fmt.Errorf("unknown plugin %q (available: %s)",
+			name, strings.Join(m.Plugins(), ", "))), nil
+	}
+
+	return plugin.Generate(req)
+}
+
+// executablePlugin returns the registered plugin named by the executable
+// path, or an empty string if there is none.
+func (m *Mux) executablePlugin(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), ".exe")
+	name = strings.TrimPrefix(name, pluginPrefix)
+
+	if _, ok := m.plugins[name]; !ok {
+		return ""
+	}
+
+	return name
+}
+
+// Install creates a protoc-gen-<name> symlink to the running executable in
+// dir for every registered plugin. Existing symlinks are replaced, while
+// other existing files are left untouched and reported as errors.
+func (m *Mux) Install(dir string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable: %w", err)
+	}
+
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return fmt.Errorf("failed to locate executable: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	for _, name := range m.Plugins() {
+		if err := installLink(exe, filepath.Join(dir, pluginPrefix+name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// installLink points link at target, replacing an existing symlink.
+func installLink(target, link string) error {
+	if info, err := os.Lstat(link); err == nil {
+		if info.Mode()&os.ModeSymlink == 0 {
+			return fmt.Errorf("%s already exists and is not a symlink", link)
+		}
+
+		if err := os.Remove(link); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", link, err)
+		}
+	}
+
+	if err := os.Symlink(target, link); err != nil {
+		return fmt.Errorf("failed to install %s: %w", link, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package ezproto
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// testMux returns a mux of two plugins, each writing a file named after it.
+func testMux() *Mux {
+	plugin := func(name string) *Plugin {
+		return NewPlugin().GenerateOnce(func(rc *RequestContext, _ []*File) error {
+			rc.NewOutput(name+".txt", "").Code().Line(name).Generate()
+
+			return nil
+		})
+	}
+
+	return NewMux().Handle("acme-sql", plugin("sql")).Handle("acme-mock", plugin("mock"))
+}
+
+func TestMuxDispatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		executable string
+		param      string
+		want       string
+		wantErr    string
+	}{
+		{name: "parameter", executable: "/bin/ezproto", param: "plugin=acme-sql", want: "sql.txt"},
+		{name: "executable", executable: "/bin/protoc-gen-acme-mock", want: "mock.txt"},
+		{name: "windows executable", executable: "protoc-gen-acme-mock.exe", want: "mock.txt"},
+		{name: "parameter over executable", executable: "/bin/protoc-gen-acme-mock", param: "plugin=acme-sql", want: "sql.txt"},
+		{name: "none", executable: "/bin/ezproto", wantErr: "no plugin selected: set plugin=<name> (available: acme-mock, acme-sql)"},
+		{name: "unknown executable", executable: "/bin/protoc-gen-other", wantErr: "no plugin selected: set plugin=<name> (available: acme-mock, acme-sql)"},
+		{name: "unknown", executable: "/bin/ezproto", param: "plugin=acme-rpc", wantErr: `unknown plugin "acme-rpc" (available: acme-mock, acme-sql)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := proto.Marshal(testRequest(tt.param))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			if err := testMux().run([]string{tt.executable}, bytes.NewReader(req), &buf); err != nil {
+				t.Fatal(err)
+			}
+
+			resp := &pluginpb.CodeGeneratorResponse{}
+			if err := proto.Unmarshal(buf.Bytes(), resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := resp.GetError(); got != tt.wantErr {
+				t.Errorf("run() error = %q, want %q", got, tt.wantErr)
+			}
+
+			var want []string
+			if tt.want != "" {
+				want = []string{tt.want}
+			}
+
+			if got := fileNames(resp); !slices.Equal(got, want) {
+				t.Errorf("run() generated %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestMuxHandleErrors(t *testing.T) {
+	for _, tt := range []struct {
+		mux  *Mux
+		want string
+	}{
+		{NewMux().Handle("", NewPlugin()), `invalid plugin name ""`},
+		{NewMux().Handle("acme/sql", NewPlugin()), `invalid plugin name "acme/sql"`},
+		{NewMux().Handle("a", NewPlugin()).Handle("a", NewPlugin()), `plugin "a" registered twice`},
+	} {
+		resp, err := tt.mux.Generate(testRequest("plugin=a"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := resp.GetError(); got != tt.want {
+			t.Errorf("Generate() error = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestMuxCommands(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testMux().run([]string{"ezproto", "list"}, nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "protoc-gen-acme-mock\nprotoc-gen-acme-sql\n"; buf.String() != want {
+		t.Errorf("list printed %q, want %q", buf.String(), want)
+	}
+
+	if err := testMux().run([]string{"ezproto", "serve"}, nil, &buf); err == nil {
+		t.Error("run(serve) succeeded, want an unknown argument error")
+	}
+}
+
+func TestMuxInstall(t *testing.T) {
+	dir := t.TempDir()
+
+	// Stale symlinks are replaced, other files are kept
+	if err := os.Symlink("/nowhere", filepath.Join(dir, "protoc-gen-acme-sql")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := testMux().Install(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"protoc-gen-acme-mock", "protoc-gen-acme-sql"} {
+		if got, err := os.Readlink(filepath.Join(dir, name)); err != nil || got != exe {
+			t.Errorf("Readlink(%s) = %q, %v, want %q", name, got, err, exe)
+		}
+	}
+
+	if err := os.Remove(filepath.Join(dir, "protoc-gen-acme-sql")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "protoc-gen-acme-sql"), nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := testMux().Install(dir); err == nil {
+		t.Error("Install() replaced a regular file")
+	}
+}
@@ -0,0 +1,88 @@
+package ezproto
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+)
+
+// PanicError reports a panic recovered from a generator or hook.
+type PanicError struct {
+	// File is the path of the proto file being generated, if any.
+	File string
+	// Generator names the generator or hook that panicked.
+	Generator string
+	// Element is the full name of the element being visited, if any.
+	Element string
+	// Value is the value passed to panic.
+	Value any
+	// Stack is the goroutine stack at the time of the panic.
+	// It is only recorded in debug mode.
+	Stack []byte
+}
+
+// Error implements the error interface.
+func (e *PanicError) Error() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "panic in %s", e.Generator)
+
+	if e.File != "" {
+		fmt.Fprintf(&sb, " for %s", e.File)
+	}
+
+	if e.Element != "" {
+		fmt.Fprintf(&sb, " while visiting %s", e.Element)
+	}
+
+	fmt.Fprintf(&sb, ": %v", e.Value)
+
+	if len(e.Stack) > 0 {
+		fmt.Fprintf(&sb, "\n%s", e.Stack)
+	}
+
+	return sb.String()
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+
+	return nil
+}
+
+// protect runs fn, converting a panic into a PanicError that names the file,
+// generator and element being processed.
+func (p *Plugin) protect(ctx *Context, name string, fn func() error) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		perr := &PanicError{
+			Generator: name,
+			Value:     r,
+		}
+
+		if ctx != nil {
+			if file := ctx.File(); file != nil {
+				perr.File = file.Name
+			}
+
+			if el := ctx.Element(); el != nil {
+				perr.Element = el.FullName()
+			}
+		}
+
+		if p.options.Debug {
+			perr.Stack = debug.Stack()
+		}
+
+		err = perr
+	}()
+
+	return fn()
+}
@@ -0,0 +1,104 @@
+package ezproto
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func TestPanicRecovery(t *testing.T) {
+	tests := []struct {
+		name   string
+		plugin *Plugin
+		want   string
+	}{
+		{
+			name:   "generator",
+			plugin: NewPlugin().GenerateFor("api/v2/*", func(*Context, *File) error { panic("bad") }),
+			want:   `panic in generator "api/v2/*" for api/v2/users.proto: bad`,
+		},
+		{
+			name: "element",
+			plugin: NewPlugin().ForEachMessage("acme.orders.v1.Order.Item", func(_ *Context, msg *Message) error {
+				return errors.New(msg.Fields()[1].Name)
+			}),
+			want: `panic in generator "messages acme.orders.v1.Order.Item" for api/v1/orders.proto while visiting acme.orders.v1.Order.Item: runtime error: index out of range [1] with length 1`,
+		},
+		{
+			name:   "parallel",
+			plugin: NewPlugin().WithOptions(Options{Concurrency: 4}).GenerateFor("internal/*", func(*Context, *File) error { panic("bad") }),
+			want:   `panic in generator "internal/*" for internal/x.proto: bad`,
+		},
+		{
+			name:   "before file",
+			plugin: NewPlugin().BeforeFile(func(*Context, *File) error { panic("bad") }),
+			want:   "panic in BeforeFile hook for acme/options.proto: bad",
+		},
+		{
+			name:   "start",
+			plugin: NewPlugin().OnStart(func(*RequestContext) error { panic("bad") }),
+			want:   "panic in OnStart hook: bad",
+		},
+		{
+			name:   "request generator",
+			plugin: NewPlugin().GenerateOnce(func(*RequestContext, []*File) error { panic("bad") }),
+			want:   "panic in request generator #0: bad",
+		},
+		{
+			name:   "finish",
+			plugin: NewPlugin().OnFinish(func(*RequestContext, *pluginpb.CodeGeneratorResponse) error { panic("bad") }),
+			want:   "panic in OnFinish hook: bad",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tt.plugin.Generate(testRequest(""))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := resp.GetError(); got != tt.want {
+				t.Errorf("Generate() error = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPanicStack(t *testing.T) {
+	resp, err := NewPlugin().
+		WithOptions(Options{Debug: true}).
+		WithLogHandler(slog.NewTextHandler(io.Discard, nil)).
+		GenerateFor("internal/*", func(*Context, *File) error { panic("bad") }).
+		Generate(testRequest(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Debug mode appends the stack of the panicking goroutine
+	got := resp.GetError()
+	if !strings.HasPrefix(got, `panic in generator "internal/*" for internal/x.proto: bad`+"\ngoroutine ") {
+		t.Errorf("Generate() error = %q, want it to carry the stack", got)
+	}
+}
+
+func TestPanicErrorUnwrap(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	err := error(&PanicError{Generator: "generator", File: "x.proto", Element: "x.X", Value: errBoom})
+	if want := "panic in generator for x.proto while visiting x.X: boom"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+
+	if !errors.Is(err, errBoom) {
+		t.Errorf("errors.Is(%v, %v) = false, want true", err, errBoom)
+	}
+
+	if errors.Unwrap(&PanicError{Value: "boom"}) != nil {
+		t.Error("Unwrap() of a non-error value is not nil")
+	}
+}
@@ -0,0 +1,143 @@
+package ezproto
+
+import (
+	"fmt"
+	"sync"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// generateFiles runs the per-file generators for files, in parallel when
+// Options.Concurrency allows it. Output files are added to the response in
+// file order regardless of which worker finishes first. In parallel mode
+// the returned outputs are only reserved in gen; their content is copied
+// by flushOutputs once every generator has run.
+func (p *Plugin) generateFiles(gen *protogen.Plugin, params parameters, files []*File, newContext func(*File) *Context) ([]*deferredOutputs, error) {
+	workers := min(p.options.Concurrency, len(files))
+	if workers < 2 {
+		for _, file := range files {
+			if err := p.generateFile(newContext(file), file); err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, nil
+	}
+
+	outputs := make([]*deferredOutputs, len(files))
+	errs := make([]error, len(files))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+
+	for range workers {
+		// Each worker renders into its own plugin, configured like the real one
+		scratch, err := newProtogenPlugin(gen.Request, params)
+		if err != nil {
+			return nil, err
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				ctx := newContext(files[i])
+				ctx.deferred = &deferredOutputs{scratch: scratch}
+				outputs[i] = ctx.deferred
+				errs[i] = p.generateFile(ctx, files[i])
+			}
+		}()
+	}
+
+	for i := range files {
+		jobs <- i
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	// Report the error of the first failing file, as sequential generation would
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, out := range outputs {
+		out.reserve(gen)
+		p.insertions = append(p.insertions, out.insertions...)
+	}
+
+	return outputs, nil
+}
+
+// flushOutputs assembles the outputs of parallel workers and copies them
+// into the files reserved for them.
+func flushOutputs(outputs []*deferredOutputs) error {
+	for _, out := range outputs {
+		out.layouts.flush()
+
+		if err := out.flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deferredOutputs collects the files generated by a worker so they can be
+// added to the response once all workers are done.
+type deferredOutputs struct {
+	scratch    *protogen.Plugin
+	files      []*deferredFile
+	insertions []*insertion
+	layouts    layouts
+}
+
+type deferredFile struct {
+	filename   string
+	importPath protogen.GoImportPath
+	file       *protogen.GeneratedFile
+	target     *protogen.GeneratedFile
+}
+
+// newGeneratedFile creates a file on the worker's private plugin, which
+// resolves imports exactly like the real one.
+func (d *deferredOutputs) newGeneratedFile(filename string, importPath protogen.GoImportPath) GeneratedFile {
+	f := d.scratch.NewGeneratedFile(filename, importPath)
+
+	d.files = append(d.files, &deferredFile{
+		filename:   filename,
+		importPath: importPath,
+		file:       f,
+	})
+
+	return f
+}
+
+// reserve creates the response files for every deferred file in gen, so
+// that they keep their position in the response.
+func (d *deferredOutputs) reserve(gen *protogen.Plugin) {
+	for _, f := range d.files {
+		f.target = gen.NewGeneratedFile(f.filename, f.importPath)
+	}
+}
+
+// flush copies the rendered content of every deferred file into the file
+// reserved for it.
+func (d *deferredOutputs) flush() error {
+	for _, f := range d.files {
+		content, err := f.file.Content()
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.filename, err)
+		}
+
+		if _, err := f.target.Write(content); err != nil {
+			return fmt.Errorf("%s: %w", f.filename, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package ezproto
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// testParallelPlugin returns a plugin whose generators finish in reverse
+// file order when run in parallel.
+func testParallelPlugin(concurrency int) *Plugin {
+	files := testRequest("").GetFileToGenerate()
+
+	return NewPlugin().
+		WithOptions(Options{Concurrency: concurrency}).
+		GenerateFor("**", func(ctx *Context, file *File) error {
+			// Later files finish first
+			time.Sleep(time.Duration(len(files)-slices.Index(files, file.Name)) * 2 * time.Millisecond)
+
+			cb := ctx.Code().Package(file.Package())
+			for _, msg := range file.Messages() {
+				cb.Line("func (x *%s) Describe() string { return %s(%q, x) }", msg.GoName(), ctx.Import("fmt")+"Sprint", msg.FullName())
+			}
+
+			cb.Generate()
+
+			return nil
+		}).
+		GenerateFor("api/**", func(ctx *Context, file *File) error {
+			out := ctx.NewOutputFile(file.Package() + "_names")
+			out.P("package ", file.Package())
+			out.P()
+			out.P("const File = ", fmt.Sprintf("%q", file.Name))
+
+			return nil
+		})
+}
+
+func TestParallelMatchesSequential(t *testing.T) {
+	for _, param := range []string{"", "paths=source_relative", "annotate_code"} {
+		want, err := testParallelPlugin(0).Generate(testRequest(param))
+		if err != nil || want.Error != nil {
+			t.Fatalf("sequential Generate(%q) = %v, %v", param, want.GetError(), err)
+		}
+
+		for _, concurrency := range []int{2, 4, 16} {
+			got, err := testParallelPlugin(concurrency).Generate(testRequest(param))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !proto.Equal(got, want) {
+				t.Errorf("Generate(%q) with concurrency %d = %v, want %v", param, concurrency, fileNames(got), fileNames(want))
+			}
+		}
+	}
+}
+
+func TestParallelFileOrder(t *testing.T) {
+	resp, err := testParallelPlugin(4).Generate(testRequest(""))
+	if err != nil || resp.Error != nil {
+		t.Fatalf("Generate() = %v, %v", resp.GetError(), err)
+	}
+
+	want := []string{
+		"options.pb.go",
+		"orders.pb.go",
+		"orders_names.go",
+		"users.pb.go",
+		"users_names.go",
+		"x.pb.go",
+	}
+	if got := fileNames(resp); !slices.Equal(got, want) {
+		t.Errorf("files = %q, want %q", got, want)
+	}
+}
+
+func TestParallelFirstError(t *testing.T) {
+	for _, concurrency := range []int{0, 4} {
+		resp, err := NewPlugin().
+			WithOptions(Options{Concurrency: concurrency}).
+			GenerateFor("**", func(_ *Context, file *File) error {
+				if file.Name == "acme/options.proto" {
+					// The first file fails last
+					time.Sleep(10 * time.Millisecond)
+				}
+
+				return errors.New("failed " + file.Name)
+			}).
+			Generate(testRequest(""))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := resp.GetError(), "generator failed for acme/options.proto: failed acme/options.proto"; got != want {
+			t.Errorf("Generate() with concurrency %d error = %q, want %q", concurrency, got, want)
+		}
+	}
+}
+
+func fileNames(resp *pluginpb.CodeGeneratorResponse) []string {
+	names := make([]string, 0, len(resp.GetFile()))
+	for _, f := range resp.GetFile() {
+		names = append(names, f.GetName())
+	}
+
+	return names
+}
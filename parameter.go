@@ -0,0 +1,235 @@
+package ezproto
+
+import (
+	"errors"
+	"strings"
+)
+
+// errUnterminatedQuote is returned when a quoted parameter value is not closed.
+var errUnterminatedQuote = errors.New("unterminated quote in plugin parameter")
+
+// parameter is a single key/value pair passed to the plugin by protoc.
+type parameter struct {
+	key      string
+	value    string
+	hasValue bool
+}
+
+// parameters holds plugin parameters in the order protoc passed them.
+type parameters []parameter
+
+// parseParameters parses plugin parameters from protoc.
+//
+// Parameters are separated by commas: "key1=value1,key2,key3=value3".
+// Values may be quoted with double or single quotes to contain commas,
+// and a backslash escapes the next character anywhere in the parameter.
+// Repeated keys are preserved in order.
+func parseParameters(s string) (parameters, error) {
+	var (
+		params  parameters
+		current parameter
+		buf     strings.Builder
+		inValue bool
+		quote   rune
+		quoted  bool
+		escaped bool
+	)
+
+	flush := func() {
+		if inValue {
+			current.value = buf.String()
+			current.hasValue = true
+
+			// Quoted values are taken verbatim
+			if !quoted {
+				current.value = strings.TrimSpace(current.value)
+			}
+		} else {
+			current.key = strings.TrimSpace(buf.String())
+		}
+
+		// Skip empty entries such as a trailing comma
+		if current.key != "" || current.hasValue {
+			params = append(params, current)
+		}
+
+		current = parameter{}
+		inValue = false
+		quoted = false
+
+		buf.Reset()
+	}
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			buf.WriteRune(r)
+
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				buf.WriteRune(r)
+			}
+		case (r == '"' || r == '\'') && inValue:
+			// Drop whitespace between '=' and the opening quote
+			if strings.TrimSpace(buf.String()) == "" {
+				buf.Reset()
+			}
+
+			quote = r
+			quoted = true
+		case r == '=' && !inValue:
+			current.key = strings.TrimSpace(buf.String())
+			inValue = true
+
+			buf.Reset()
+		case r == ',':
+			flush()
+		default:
+			buf.WriteRune(r)
+		}
+	}
+
+	if quote != 0 {
+		return nil, errUnterminatedQuote
+	}
+
+	if escaped {
+		buf.WriteRune('\\')
+	}
+
+	flush()
+
+	return params, nil
+}
+
+// values returns all values given for key, in order.
+// A key given without a value contributes an empty string.
+func (ps parameters) values(key string) []string {
+	var values []string
+
+	for _, p := range ps {
+		if p.key == key {
+			values = append(values, p.value)
+		}
+	}
+
+	return values
+}
+
+// flag reports whether key was given without a value, e.g. "debug" rather than "debug=true".
+func (ps parameters) flag(key string) bool {
+	for _, p := range ps {
+		if p.key == key && !p.hasValue {
+			return true
+		}
+	}
+
+	return false
+}
+
+// toMap returns the last value given for each key.
+// Keys given without a value map to "true".
+func (ps parameters) toMap() map[string]string {
+	m := make(map[string]string, len(ps))
+
+	for _, p := range ps {
+		if p.hasValue {
+			m[p.key] = p.value
+		} else {
+			m[p.key] = "true"
+		}
+	}
+
+	return m
+}
+
+// grouped returns all values for each key, in order.
+func (ps parameters) grouped() map[string][]string {
+	m := make(map[string][]string, len(ps))
+
+	for _, p := range ps {
+		m[p.key] = append(m[p.key], p.value)
+	}
+
+	return m
+}
+
+// protogenParameter re-encodes the parameters understood by protogen,
+// so that quoted values containing commas cannot confuse its simpler parser.
+func (ps parameters) protogenParameter() string {
+	var parts []string
+
+	for _, p := range ps {
+		switch {
+		case p.key == "module", p.key == "paths", p.key == "annotate_code", p.key == "default_api_level",
+			isMappingParameter(p.key):
+		default:
+			continue
+		}
+
+		if p.hasValue {
+			parts = append(parts, p.key+"="+p.value)
+		} else {
+			parts = append(parts, p.key)
+		}
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// without returns the parameters other than those named key.
+func (ps parameters) without(key string) parameters {
+	var kept parameters
+
+	for _, p := range ps {
+		if p.key != key {
+			kept = append(kept, p)
+		}
+	}
+
+	return kept
+}
+
+// String encodes the parameters in the form accepted by parseParameters,
+// quoting values that would otherwise not survive a round trip.
+func (ps parameters) String() string {
+	parts := make([]string, 0, len(ps))
+
+	for _, p := range ps {
+		key := escapeParameter(p.key, ",=\\\"'")
+		if !p.hasValue {
+			parts = append(parts, key)
+
+			continue
+		}
+
+		value := p.value
+		if value != strings.TrimSpace(value) || strings.ContainsAny(value, ",\\\"'") {
+			value = `"` + escapeParameter(value, `\"`) + `"`
+		}
+
+		parts = append(parts, key+"="+value)
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// escapeParameter escapes every character of s found in special with a backslash.
+func escapeParameter(s, special string) string {
+	var sb strings.Builder
+
+	for _, r := range s {
+		if strings.ContainsRune(special, r) {
+			sb.WriteRune('\\')
+		}
+
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
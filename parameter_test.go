@@ -0,0 +1,137 @@
+package ezproto
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseParameters(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  parameters
+	}{
+		{
+			name:  "empty",
+			input: "",
+			want:  nil,
+		},
+		{
+			name:  "flags and values",
+			input: "debug,paths=source_relative, format = yaml ",
+			want: parameters{
+				{key: "debug"},
+				{key: "paths", value: "source_relative", hasValue: true},
+				{key: "format", value: "yaml", hasValue: true},
+			},
+		},
+		{
+			name:  "empty value",
+			input: "format=",
+			want:  parameters{{key: "format", value: "", hasValue: true}},
+		},
+		{
+			name:  "repeated keys",
+			input: "exclude=a,exclude=b,exclude",
+			want: parameters{
+				{key: "exclude", value: "a", hasValue: true},
+				{key: "exclude", value: "b", hasValue: true},
+				{key: "exclude"},
+			},
+		},
+		{
+			name:  "quoted values",
+			input: `a="x,y", b='say "hi"',c=" padded "`,
+			want: parameters{
+				{key: "a", value: "x,y", hasValue: true},
+				{key: "b", value: `say "hi"`, hasValue: true},
+				{key: "c", value: " padded ", hasValue: true},
+			},
+		},
+		{
+			name:  "escapes",
+			input: `a=x\,y,b\=c=d,e=trailing\`,
+			want: parameters{
+				{key: "a", value: "x,y", hasValue: true},
+				{key: "b=c", value: "d", hasValue: true},
+				{key: "e", value: `trailing\`, hasValue: true},
+			},
+		},
+		{
+			name:  "equals in value",
+			input: "Mfoo.proto=example.com/foo;foo,opt=a=b",
+			want: parameters{
+				{key: "Mfoo.proto", value: "example.com/foo;foo", hasValue: true},
+				{key: "opt", value: "a=b", hasValue: true},
+			},
+		},
+		{
+			name:  "empty entries",
+			input: ",a,,b,",
+			want:  parameters{{key: "a"}, {key: "b"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseParameters(tt.input)
+			if err != nil {
+				t.Fatalf("parseParameters(%q) error = %v", tt.input, err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseParameters(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+
+			// Encoding and parsing again must give the same parameters
+			again, err := parseParameters(got.String())
+			if err != nil || !reflect.DeepEqual(again, got) {
+				t.Errorf("parseParameters(%q) = %+v, %v, want %+v", got.String(), again, err, got)
+			}
+		})
+	}
+}
+
+func TestParseParametersUnterminatedQuote(t *testing.T) {
+	for _, input := range []string{`a="x`, `a='x,b=y`} {
+		if _, err := parseParameters(input); !errors.Is(err, errUnterminatedQuote) {
+			t.Errorf("parseParameters(%q) error = %v, want %v", input, err, errUnterminatedQuote)
+		}
+	}
+}
+
+func TestParametersAccessors(t *testing.T) {
+	params, err := parseParameters("exclude=a,debug,exclude=b,format=json,format=yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := params.values("exclude"), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("values(exclude) = %q, want %q", got, want)
+	}
+
+	if !params.flag("debug") || params.flag("format") {
+		t.Errorf("flag(debug), flag(format) = %v, %v, want true, false", params.flag("debug"), params.flag("format"))
+	}
+
+	want := map[string]string{"exclude": "b", "debug": "true", "format": "yaml"}
+	if got := params.toMap(); !reflect.DeepEqual(got, want) {
+		t.Errorf("toMap() = %v, want %v", got, want)
+	}
+
+	if got := params.without("exclude").String(); got != "debug,format=json,format=yaml" {
+		t.Errorf("without(exclude) = %q", got)
+	}
+}
+
+func TestProtogenParameter(t *testing.T) {
+	params, err := parseParameters(`paths=source_relative,format="a,b",Mfoo.proto=example.com/foo,Mode=x,annotate_code,debug`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := params.protogenParameter(), "paths=source_relative,Mfoo.proto=example.com/foo,annotate_code"; got != want {
+		t.Errorf("protogenParameter() = %q, want %q", got, want)
+	}
+}
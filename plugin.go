@@ -1,12 +1,20 @@
 package ezproto
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"text/template"
+	"time"
 
 	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
 )
 
 // GeneratorFunc is a function that generates code for a given proto file.
@@ -16,13 +24,36 @@ type GeneratorFunc func(ctx *Context, file *File) error
 type Options struct {
 	Debug          bool
 	PackageMapping map[string]string
+	// Concurrency is the number of files generated in parallel.
+	// Values below 2 generate files sequentially.
+	Concurrency int
 }
 
 // Plugin represents an ezproto code generator plugin.
 type Plugin struct {
 	options          Options
-	generators       map[string]GeneratorFunc
+	generators       []*registration
+	onceGenerators   []RequestGeneratorFunc
 	parameterHandler func(params map[string]string, options *Options)
+	config           any
+	hooks            hooks
+	errs             []error
+	logHandler       slog.Handler
+	logger           *slog.Logger
+	closeLog         func()
+	insertions       []*insertion
+	layouts          layouts
+	symbols          *symbolTable
+	templates        *template.Template
+}
+
+// registration is a generator together with the files it runs for.
+type registration struct {
+	name      string
+	selector  Selector
+	generator GeneratorFunc
+	// perElement is set for generators that apply middleware per element.
+	perElement bool
 }
 
 // NewPlugin creates a new Plugin instance.
@@ -32,7 +63,6 @@ func NewPlugin() *Plugin {
 			Debug:          false,
 			PackageMapping: make(map[string]string),
 		},
-		generators: make(map[string]GeneratorFunc),
 	}
 }
 
@@ -47,8 +77,35 @@ func (p *Plugin) WithOptions(opts Options) *Plugin {
 }
 
 // GenerateFor registers a generator function for files matching the given pattern.
+// See Select for the pattern syntax. A malformed pattern is reported when the
+// plugin runs. Generators run in registration order.
 func (p *Plugin) GenerateFor(pattern string, generator GeneratorFunc) *Plugin {
-	p.generators[pattern] = generator
+	selector, err := Select(pattern)
+	if err != nil {
+		p.errs = append(p.errs, err)
+
+		return p
+	}
+
+	return p.register(pattern, selector, generator)
+}
+
+// GenerateForSelector registers a generator function for files chosen by selector.
+func (p *Plugin) GenerateForSelector(selector Selector, generator GeneratorFunc) *Plugin {
+	return p.register(fmt.Sprintf("selector#%d", len(p.generators)), selector, generator)
+}
+
+// GenerateWhen registers a generator function for files for which predicate returns true.
+func (p *Plugin) GenerateWhen(predicate func(*File) bool, generator GeneratorFunc) *Plugin {
+	return p.register(fmt.Sprintf("predicate#%d", len(p.generators)), SelectorFunc(predicate), generator)
+}
+
+func (p *Plugin) register(name string, selector Selector, generator GeneratorFunc) *Plugin {
+	p.generators = append(p.generators, &registration{
+		name:      name,
+		selector:  selector,
+		generator: generator,
+	})
 
 	return p
 }
@@ -61,83 +118,295 @@ func (p *Plugin) WithParameterHandler(handler func(params map[string]string, opt
 }
 
 // Run executes the plugin by processing proto files with protoc.
+// It reads a CodeGeneratorRequest from stdin and writes the response to stdout.
+//
+// Run directly with the gen command, the plugin generates from a descriptor
+// set without protoc:
+//
+//	myplugin gen --descriptor-set=api.binpb --files=a.proto,b.proto --out=./gen --param debug=true
+//
+// The replay command re-runs a request saved with the dump_request parameter:
+//
+//	myplugin replay --request=req.binpb --out=./gen --diff
 func (p *Plugin) Run() error {
-	opts := protogen.Options{}
-	opts.Run(func(gen *protogen.Plugin) error {
-		// Parse plugin parameters
-		params := parseParameters(gen.Request.GetParameter())
+	if err := p.run(os.Args, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", filepath.Base(os.Args[0]), err)
+		os.Exit(1)
+	}
 
-		// Update plugin options with parsed parameters
-		p.updateOptionsFromParams(params)
+	return nil
+}
 
-		// Call custom parameter handler if provided
-		if p.parameterHandler != nil {
-			p.parameterHandler(params, &p.options)
+func (p *Plugin) run(args []string, r io.Reader, w io.Writer) error {
+	if len(args) > 1 {
+		switch args[1] {
+		case "gen":
+			return runGen(args[2:], p.Generate)
+		case "replay":
+			return runReplay(args[2:], w, p.Generate)
+		default:
+			return fmt.Errorf("unknown argument %q (this program should be run by protoc, or with the gen or replay command)", args[1])
 		}
+	}
 
-		for _, f := range gen.Files {
-			if !f.Generate {
-				continue
-			}
+	return serve(r, w, p.Generate)
+}
 
-			file := &File{
-				proto: f,
-				Name:  f.Desc.Path(),
-			}
+// serve reads a CodeGeneratorRequest from r, generates a response and writes
+// it to w.
+func serve(r io.Reader, w io.Writer, generate generateFunc) error {
+	in, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read request: %w", err)
+	}
 
-			ctx := &Context{
-				plugin:     p,
-				gen:        gen,
-				file:       f,
-				parameters: params,
-			}
+	req := &pluginpb.CodeGeneratorRequest{}
+	if err := proto.Unmarshal(in, req); err != nil {
+		return fmt.Errorf("failed to parse request: %w", err)
+	}
 
-			for pattern, generator := range p.generators {
-				if p.matchesPattern(f.Desc.Path(), pattern) {
-					if p.options.Debug {
-						fmt.Fprintf(os.Stderr, "[DEBUG] Generating for %s with pattern %s\n", f.Desc.Path(), pattern)
-					}
+	resp, err := generate(req)
+	if err != nil {
+		return err
+	}
 
-					if err := generator(ctx, file); err != nil {
-						return fmt.Errorf("generator failed for %s: %w", f.Desc.Path(), err)
-					}
-				}
-			}
+	out, err := proto.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("failed to marshal response: %w", err)
+	}
+
+	if _, err := w.Write(out); err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+
+	return nil
+}
+
+// Generate runs all registered generators against a CodeGeneratorRequest.
+// Errors reported by generators are returned in the response; the returned
+// error is reserved for requests that cannot be processed at all.
+func (p *Plugin) Generate(req *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+	params, err := parseParameters(req.GetParameter())
+	if err != nil {
+		return errorResponse(err), nil
+	}
+
+	// Save the request first so that failing runs can be replayed too
+	if values := params.values("dump_request"); len(values) > 0 {
+		if err := dumpRequest(req, values[len(values)-1]); err != nil {
+			return errorResponse(err), nil
 		}
+	}
 
-		return nil
+	gen, err := newProtogenPlugin(req, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process request: %w", err)
+	}
+
+	rc, err := p.generate(gen, params)
+	defer p.resetLogger()
+
+	if err != nil {
+		gen.Error(err)
+	}
+
+	p.layouts.flush()
+
+	resp := gen.Response()
+	p.appendInsertions(resp)
+
+	// Finish hooks see the final response, including failures
+	if rc != nil {
+		err := p.protect(nil, "OnFinish hook", func() error { return p.runFinishHooks(rc, resp) })
+		if err != nil {
+			return errorResponse(err), nil
+		}
+	}
+
+	return resp, nil
+}
+
+// newProtogenPlugin creates a protogen plugin for req. protogen parses the
+// parameter itself, so it is only handed the keys it understands.
+func newProtogenPlugin(req *pluginpb.CodeGeneratorRequest, params parameters) (*protogen.Plugin, error) {
+	gen, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{
+		FileToGenerate:        req.GetFileToGenerate(),
+		Parameter:             proto.String(params.protogenParameter()),
+		ProtoFile:             req.GetProtoFile(),
+		SourceFileDescriptors: req.GetSourceFileDescriptors(),
+		CompilerVersion:       req.GetCompilerVersion(),
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	return nil
+	gen.Request = req
+
+	return gen, nil
 }
 
-// parseParameters parses plugin parameters from protoc.
-func parseParameters(parameter string) map[string]string {
-	params := make(map[string]string)
-	if parameter == "" {
-		return params
+// generate runs hooks and generators. The returned RequestContext is nil if
+// the request failed before the model was built.
+func (p *Plugin) generate(gen *protogen.Plugin, params parameters) (*RequestContext, error) {
+	// Report registration errors such as malformed patterns
+	if err := errors.Join(p.errs...); err != nil {
+		return nil, err
 	}
 
-	// Split by comma: "key1=value1,key2=value2"
-	pairs := strings.Split(parameter, ",")
-	for _, pair := range pairs {
-		if kv := strings.SplitN(pair, "=", 2); len(kv) == 2 {
-			params[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
-		} else {
-			// Boolean flag without value
-			params[strings.TrimSpace(pair)] = "true"
+	paramMap := params.toMap()
+
+	// Update plugin options with parsed parameters
+	p.updateOptionsFromParams(params)
+
+	// Call custom parameter handler if provided
+	if p.parameterHandler != nil {
+		p.parameterHandler(paramMap, &p.options)
+	}
+
+	// Bind parameters into the user config if provided
+	if err := p.bindConfig(params); err != nil {
+		return nil, err
+	}
+
+	if err := p.setupLogger(params); err != nil {
+		return nil, err
+	}
+
+	// Build the model once so that every generator sees the same elements
+	m := newModel(gen)
+	p.symbols = newSymbolTable(gen)
+
+	rc := &RequestContext{
+		plugin:     p,
+		gen:        gen,
+		model:      m,
+		parameters: paramMap,
+		params:     params,
+	}
+
+	if err := p.protect(nil, "OnStart hook", func() error { return p.runStartHooks(rc) }); err != nil {
+		return rc, err
+	}
+
+	files := m.generated()
+
+	newContext := func(file *File) *Context {
+		return &Context{
+			plugin:     p,
+			gen:        gen,
+			file:       file.proto,
+			model:      m,
+			order:      slices.Index(files, file),
+			importPath: file.proto.GoImportPath,
+			parameters: paramMap,
+			params:     params,
 		}
 	}
 
-	return params
+	outputs, err := p.generateFiles(gen, params, files, newContext)
+	if err != nil {
+		return rc, err
+	}
+
+	// Whole-request generators run once every file has been processed
+	for i, generator := range p.onceGenerators {
+		err := p.protect(nil, fmt.Sprintf("request generator #%d", i), func() error {
+			return generator(rc, rc.Files())
+		})
+
+		var perr *PanicError
+		if errors.As(err, &perr) {
+			return rc, err
+		}
+
+		if err != nil {
+			return rc, fmt.Errorf("request generator failed: %w", err)
+		}
+	}
+
+	// Shared declarations go to their first file before outputs are rendered
+	if err := p.symbols.resolve(); err != nil {
+		return rc, err
+	}
+
+	return rc, flushOutputs(outputs)
+}
+
+// generateFile runs every matching generator for a single file, surrounded
+// by the file hooks.
+func (p *Plugin) generateFile(ctx *Context, file *File) error {
+	err := p.protect(ctx, "BeforeFile hook", func() error {
+		return p.runFileHooks(p.hooks.beforeFile, ctx, file)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, reg := range p.generators {
+		if !reg.selector.Match(file) {
+			continue
+		}
+
+		ctx.generator = reg.name
+		ctx.Logger().Debug("running generator")
+
+		start := time.Now()
+		err := p.protect(ctx, fmt.Sprintf("generator %q", reg.name), func() error {
+			if reg.perElement {
+				return reg.generator(ctx, file)
+			}
+
+			return p.wrap(reg.generator)(ctx, file)
+		})
+
+		ctx.Logger().Debug("generator finished", "duration", time.Since(start))
+
+		var perr *PanicError
+		if errors.As(err, &perr) {
+			return err
+		}
+
+		if err != nil {
+			return fmt.Errorf("generator failed for %s: %w", file.Name, err)
+		}
+	}
+
+	ctx.generator = ""
+
+	return p.protect(ctx, "AfterFile hook", func() error {
+		return p.runFileHooks(p.hooks.afterFile, ctx, file)
+	})
+}
+
+// errorResponse returns a response reporting err to protoc.
+func errorResponse(err error) *pluginpb.CodeGeneratorResponse {
+	return &pluginpb.CodeGeneratorResponse{
+		Error: proto.String(err.Error()),
+	}
+}
+
+// bindConfig binds parsed parameters into the config registered with WithConfig.
+func (p *Plugin) bindConfig(params parameters) error {
+	if p.config == nil {
+		return nil
+	}
+
+	spec, err := newConfigSpec(p.config)
+	if err != nil {
+		return err
+	}
+
+	return spec.bind(p.config, params.grouped())
 }
 
 // updateOptionsFromParams updates plugin options from parsed parameters.
-func (p *Plugin) updateOptionsFromParams(params map[string]string) {
-	for key, value := range params {
+func (p *Plugin) updateOptionsFromParams(params parameters) {
+	for _, param := range params {
+		key, value := param.key, param.value
+
 		switch key {
 		case "debug":
-			p.options.Debug = value == "true" || value == "1"
+			p.options.Debug = !param.hasValue || value == "true" || value == "1"
 		case "package_mapping":
 			// Handle package mapping: package_mapping=proto.package:go.package
 			if mapping := strings.SplitN(value, ":", 2); len(mapping) == 2 {
@@ -150,16 +419,3 @@ func (p *Plugin) updateOptionsFromParams(params map[string]string) {
 		}
 	}
 }
-
-func (p *Plugin) matchesPattern(path, pattern string) bool {
-	if pattern == "*" || pattern == "*.proto" {
-		return true
-	}
-
-	matched, err := filepath.Match(pattern, path)
-	if err != nil {
-		return strings.Contains(path, strings.TrimSuffix(pattern, "*"))
-	}
-
-	return matched
-}
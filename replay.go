@@ -0,0 +1,178 @@
+package ezproto
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// errOutputsDiffer is returned by the replay command when --diff finds changes.
+var errOutputsDiffer = errors.New("generated files differ from the output directory")
+
+// dumpRequest saves req to path in binary form and to path.json as JSON,
+// so that it can be replayed later.
+func dumpRequest(req *pluginpb.CodeGeneratorRequest, path string) error {
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to dump request: %w", err)
+	}
+
+	data, err = protojson.MarshalOptions{Multiline: true}.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	if err := os.WriteFile(path+".json", data, 0o644); err != nil {
+		return fmt.Errorf("failed to dump request: %w", err)
+	}
+
+	return nil
+}
+
+// ReadRequest reads a CodeGeneratorRequest saved with the dump_request
+// parameter. Files ending in .json are read as JSON, others as binary.
+func ReadRequest(path string) (*pluginpb.CodeGeneratorRequest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request: %w", err)
+	}
+
+	req := &pluginpb.CodeGeneratorRequest{}
+
+	if strings.HasSuffix(path, ".json") {
+		err = protojson.Unmarshal(data, req)
+	} else {
+		err = proto.Unmarshal(data, req)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse request %s: %w", path, err)
+	}
+
+	return req, nil
+}
+
+// Replay re-runs the plugin against a request saved with the dump_request
+// parameter, which makes it easy to step through generators in a debugger
+// or to reproduce a protoc run from a test.
+func (p *Plugin) Replay(path string) (*pluginpb.CodeGeneratorResponse, error) {
+	return replay(path, p.Generate)
+}
+
+// replay reads a saved request and generates a response for it, dropping
+// dump_request so that the saved request is not overwritten.
+func replay(path string, generate generateFunc) (*pluginpb.CodeGeneratorResponse, error) {
+	req, err := ReadRequest(path)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := parseParameters(req.GetParameter())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(params.values("dump_request")) > 0 {
+		req.Parameter = proto.String(params.without("dump_request").String())
+	}
+
+	return generate(req)
+}
+
+// runReplay implements the replay command:
+//
+//	replay --request=path [--out=dir] [--diff]
+//
+// Without --out the names of the generated files are listed. With --out
+// they are written below dir, or with --diff compared against the files
+// already there, printing a unified diff of every change.
+func runReplay(args []string, w io.Writer, generate generateFunc) error {
+	var (
+		request string
+		out     string
+		diff    bool
+	)
+
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.StringVar(&request, "request", "", "path to a request saved with dump_request")
+	flags.StringVar(&out, "out", "", "output directory")
+	flags.BoolVar(&diff, "diff", false, "compare outputs against the output directory instead of writing them")
+
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+
+	if flags.NArg() > 0 {
+		return fmt.Errorf("replay: unexpected argument %q", flags.Arg(0))
+	}
+
+	if request == "" {
+		return errors.New("replay: --request is required")
+	}
+
+	resp, err := replay(request, generate)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case diff:
+		return diffResponse(w, resp, out)
+	case out != "":
+		return WriteResponse(resp, out)
+	default:
+		outputs, err := resolveResponse(resp, "")
+		if err != nil {
+			return err
+		}
+
+		for _, o := range outputs {
+			fmt.Fprintln(w, o.name)
+		}
+
+		return nil
+	}
+}
+
+// diffResponse prints a unified diff between the files on disk below dir
+// and the files of resp.
+func diffResponse(w io.Writer, resp *pluginpb.CodeGeneratorResponse, dir string) error {
+	outputs, err := resolveResponse(resp, dir)
+	if err != nil {
+		return err
+	}
+
+	differ := false
+
+	for _, o := range outputs {
+		before, err := os.ReadFile(o.path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to read %s: %w", o.name, err)
+		}
+
+		name := filepath.ToSlash(o.name)
+		if d := unifiedDiff("a/"+name, "b/"+name, before, o.content); d != "" {
+			fmt.Fprint(w, d)
+
+			differ = true
+		}
+	}
+
+	if differ {
+		return errOutputsDiffer
+	}
+
+	return nil
+}
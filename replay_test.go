@@ -0,0 +1,98 @@
+package ezproto
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "req.binpb")
+
+	p := NewPlugin().GenerateFor("internal/*", func(ctx *Context, file *File) error {
+		ctx.Code().Package(file.Package()).Line("const Name = %q", file.Name).Generate()
+
+		return nil
+	})
+
+	want, err := p.Generate(testRequest("dump_request=" + path))
+	if err != nil || want.Error != nil {
+		t.Fatalf("Generate() = %v, %v", want.GetError(), err)
+	}
+
+	for _, name := range []string{path, path + ".json"} {
+		req, err := ReadRequest(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !proto.Equal(req.GetProtoFile()[1], testRequest("").GetProtoFile()[1]) {
+			t.Errorf("ReadRequest(%s) does not hold the dumped request", name)
+		}
+	}
+
+	// Replaying must not overwrite the saved request
+	if err := os.Remove(path + ".json"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := p.Replay(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !proto.Equal(got, want) {
+		t.Errorf("Replay() = %v, want %v", got, want)
+	}
+
+	if _, err := os.Stat(path + ".json"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Replay() dumped the request again")
+	}
+}
+
+func TestReplayDiff(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "req.binpb")
+	out := filepath.Join(dir, "out")
+
+	p := NewPlugin().GenerateFor("internal/*", func(ctx *Context, file *File) error {
+		ctx.Code().Package(file.Package()).Line("const Name = %q", file.Name).Generate()
+
+		return nil
+	})
+
+	resp, err := p.Generate(testRequest("dump_request=" + path))
+	if err != nil || resp.Error != nil {
+		t.Fatalf("Generate() = %v, %v", resp.GetError(), err)
+	}
+
+	if err := WriteResponse(resp, out); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	args := []string{"--request=" + path, "--out=" + out, "--diff"}
+	if err := runReplay(args, &buf, p.Generate); err != nil || buf.Len() > 0 {
+		t.Fatalf("runReplay(%q) = %q, %v, want no differences", args, buf.String(), err)
+	}
+
+	written := filepath.Join(out, "x.pb.go")
+	if err := os.WriteFile(written, []byte("package x\n\nconst Name = \"old\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runReplay(args, &buf, p.Generate); !errors.Is(err, errOutputsDiffer) {
+		t.Fatalf("runReplay(%q) error = %v, want %v", args, err, errOutputsDiffer)
+	}
+
+	want := "--- a/x.pb.go\n+++ b/x.pb.go\n@@ -1,3 +1,3 @@\n package x\n \n-const Name = \"old\"\n+const Name = \"internal/x.proto\"\n"
+	if got := buf.String(); got != want {
+		t.Errorf("runReplay(%q) printed %q, want %q", args, got, want)
+	}
+}
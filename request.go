@@ -0,0 +1,95 @@
+package ezproto
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// RequestGeneratorFunc is a function that generates code for a whole request.
+type RequestGeneratorFunc func(ctx *RequestContext, files []*File) error
+
+// RequestContext provides access to every file of a request and lets
+// whole-request generators write outputs into any Go package or path.
+type RequestContext struct {
+	plugin     *Plugin
+	gen        *protogen.Plugin
+	model      *model
+	parameters map[string]string
+	params     parameters
+}
+
+// GenerateOnce registers a generator that runs once per request, after all
+// per-file generators, with the files protoc asked to generate.
+// It can be used to produce registries, indexes or wiring that span files.
+func (p *Plugin) GenerateOnce(generator RequestGeneratorFunc) *Plugin {
+	p.onceGenerators = append(p.onceGenerators, generator)
+
+	return p
+}
+
+// Request returns the CodeGeneratorRequest sent by protoc.
+func (rc *RequestContext) Request() *pluginpb.CodeGeneratorRequest {
+	return rc.gen.Request
+}
+
+// Files returns all proto files that are being generated.
+func (rc *RequestContext) Files() []*File {
+	return rc.model.generated()
+}
+
+// AllFiles returns every proto file in the request, including imported ones.
+func (rc *RequestContext) AllFiles() []*File {
+	return rc.model.files
+}
+
+// FileByPath returns the file with the given path, including imported files.
+func (rc *RequestContext) FileByPath(path string) (*File, bool) {
+	file, ok := rc.model.byPath[path]
+
+	return file, ok
+}
+
+// NewOutput creates an output file at filename, relative to the protoc output
+// directory, belonging to the Go package importPath. The returned Context
+// writes to that file, so its CodeBuilders and Import resolve identifiers
+// relative to importPath. Files without a .go suffix, such as JSON or YAML
+// documents, are written verbatim.
+func (rc *RequestContext) NewOutput(filename, importPath string) *Context {
+	ctx := &Context{
+		plugin:     rc.plugin,
+		gen:        rc.gen,
+		model:      rc.model,
+		order:      math.MaxInt, // after every per-file output
+		importPath: protogen.GoImportPath(importPath),
+		parameters: rc.parameters,
+		params:     rc.params,
+	}
+
+	ctx.output = rc.gen.NewGeneratedFile(filename, ctx.importPath)
+
+	return ctx
+}
+
+// Parameters returns the plugin parameters passed from protoc.
+func (rc *RequestContext) Parameters() map[string]string {
+	return rc.parameters
+}
+
+// ParameterValues returns every value given for a parameter, in order.
+func (rc *RequestContext) ParameterValues(key string) []string {
+	return rc.params.values(key)
+}
+
+// HasFlag returns true if the parameter was given without a value.
+func (rc *RequestContext) HasFlag(key string) bool {
+	return rc.params.flag(key)
+}
+
+// GetParameter returns a specific parameter value.
+func (rc *RequestContext) GetParameter(key string) (string, bool) {
+	value, exists := rc.parameters[key]
+
+	return value, exists
+}
@@ -0,0 +1,81 @@
+package ezproto
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGenerateOnce(t *testing.T) {
+	var calls []string
+
+	helper := func(ctx *Context) {
+		ctx.DeclareOnce("helper", func(cb *CodeBuilder) { cb.Line("func helper() {}") })
+	}
+
+	resp, err := NewPlugin().
+		// Registered first, but still run after the per-file generators
+		GenerateOnce(func(rc *RequestContext, files []*File) error {
+			calls = append(calls, "once")
+
+			names := make([]string, 0, len(files))
+			for _, f := range files {
+				names = append(names, f.Name)
+			}
+
+			if want := []string{"acme/options.proto", "api/v1/orders.proto", "api/v2/users.proto", "internal/x.proto"}; !slices.Equal(names, want) {
+				t.Errorf("GenerateOnce got files %q, want %q", names, want)
+			}
+
+			if len(rc.AllFiles()) != 5 {
+				t.Errorf("AllFiles() has %d files, want 5", len(rc.AllFiles()))
+			}
+
+			out := rc.NewOutput("orders/registry.go", "example.com/api/v1/orders")
+			out.Code().Package("orders").
+				Line("var _ = %s", out.Import("example.com/api/v2/users")+"User{}").
+				Line("var _ = Order{}").
+				Generate()
+			helper(out)
+
+			rc.NewOutput("index.json", "").Code().Line(`{"files": %d}`, len(files)).Generate()
+
+			return nil
+		}).
+		GenerateFor("api/v1/*", func(ctx *Context, file *File) error {
+			calls = append(calls, file.Name)
+			ctx.Code().Package(file.Package()).Generate()
+			helper(ctx)
+
+			return nil
+		}).
+		Generate(testRequest(""))
+	if err != nil || resp.Error != nil {
+		t.Fatalf("Generate() = %v, %v", resp.GetError(), err)
+	}
+
+	if want := []string{"api/v1/orders.proto", "once"}; !slices.Equal(calls, want) {
+		t.Errorf("generators ran as %q, want %q", calls, want)
+	}
+
+	// Request outputs come after every per-file output
+	if got, want := fileNames(resp), []string{"orders.pb.go", "orders/registry.go", "index.json"}; !slices.Equal(got, want) {
+		t.Fatalf("generated %q, want %q", got, want)
+	}
+
+	files := resp.GetFile()
+
+	// The per-file output is ordered first, so it declares the helper
+	if !strings.Contains(files[0].GetContent(), "func helper() {}") || strings.Contains(files[1].GetContent(), "func helper") {
+		t.Errorf("helper declared in\n%s\nand\n%s", files[0].GetContent(), files[1].GetContent())
+	}
+
+	want := "package orders\n\nimport (\n\tusers \"example.com/api/v2/users\"\n)\n\nvar _ = users.User{}\nvar _ = Order{}\n"
+	if got := files[1].GetContent(); got != want {
+		t.Errorf("registry.go = %q, want %q", got, want)
+	}
+
+	if got := files[2].GetContent(); got != `{"files": 4}`+"\n" {
+		t.Errorf("index.json = %q", got)
+	}
+}
@@ -0,0 +1,154 @@
+package ezproto
+
+import "slices"
+
+// Canonical sections of a file layout, in the order they are assembled.
+const (
+	SectionHeader    = "header"
+	SectionImports   = "imports"
+	SectionConstants = "constants"
+	SectionTypes     = "types"
+	SectionFunctions = "functions"
+	SectionInit      = "init"
+)
+
+// defaultSections is the canonical section order of every layout.
+var defaultSections = []string{
+	SectionHeader,
+	SectionImports,
+	SectionConstants,
+	SectionTypes,
+	SectionFunctions,
+	SectionInit,
+}
+
+// layout collects the code written into the named sections of one output
+// file until the file is assembled.
+type layout struct {
+	output   GeneratedFile
+	order    []string
+	sections map[string][]string
+}
+
+// define adds a section after another, or at the end if after is unknown.
+// Defining an existing section does nothing.
+func (l *layout) define(name, after string) {
+	if slices.Contains(l.order, name) {
+		return
+	}
+
+	i := slices.Index(l.order, after)
+	if i < 0 {
+		l.order = append(l.order, name)
+
+		return
+	}
+
+	l.order = slices.Insert(l.order, i+1, name)
+}
+
+func (l *layout) append(section string, lines []string) {
+	l.define(section, "")
+	l.sections[section] = append(l.sections[section], lines...)
+}
+
+// flush writes the sections to the output in order, separated by blank lines.
+func (l *layout) flush() {
+	first := true
+
+	for _, name := range l.order {
+		lines := l.sections[name]
+		if len(lines) == 0 {
+			continue
+		}
+
+		if !first {
+			l.output.P()
+		}
+
+		first = false
+
+		for _, line := range lines {
+			l.output.P(line)
+		}
+	}
+}
+
+// layouts tracks the layouts of the output files of a run, in creation order.
+type layouts struct {
+	order  []*layout
+	byFile map[GeneratedFile]*layout
+}
+
+func (ls *layouts) get(output GeneratedFile) *layout {
+	if l, ok := ls.byFile[output]; ok {
+		return l
+	}
+
+	if ls.byFile == nil {
+		ls.byFile = make(map[GeneratedFile]*layout)
+	}
+
+	l := &layout{
+		output:   output,
+		order:    slices.Clone(defaultSections),
+		sections: make(map[string][]string),
+	}
+
+	ls.byFile[output] = l
+	ls.order = append(ls.order, l)
+
+	return l
+}
+
+// flush assembles every layout into its file and resets the tracker.
+func (ls *layouts) flush() {
+	for _, l := range ls.order {
+		l.flush()
+	}
+
+	*ls = layouts{}
+}
+
+// layout returns the layout of the context's output file.
+func (c *Context) layout() *layout {
+	if c.output == nil {
+		c.createOutputFile()
+	}
+
+	if c.deferred != nil {
+		return c.deferred.layouts.get(c.output)
+	}
+
+	return c.plugin.layouts.get(c.output)
+}
+
+// Section returns a CodeBuilder whose Generate appends to the named section
+// of the output file instead of writing immediately. Sections are assembled
+// in order once all generators have run, so generators can add constants,
+// types or init code in any order and still produce a canonically laid out
+// file. Code written with a plain Generate precedes all sections.
+//
+// The canonical sections are SectionHeader, SectionImports,
+// SectionConstants, SectionTypes, SectionFunctions and SectionInit. Other
+// names are placed after them unless positioned with DefineSection.
+func (c *Context) Section(name string) *CodeBuilder {
+	cb := c.NewCodeBuilder()
+	cb.section = name
+
+	return cb
+}
+
+// DefineSection adds a section, or anchor, to the output file's layout
+// directly after the section named after, so that generators can agree on
+// placement, e.g. DefineSection("validators", SectionFunctions).
+// Sections that already exist keep their position.
+func (c *Context) DefineSection(name, after string) {
+	c.layout().define(name, after)
+}
+
+// GenerateIn appends the accumulated code to the named section of the output
+// file instead of writing it immediately; see Context.Section.
+func (cb *CodeBuilder) GenerateIn(section string) {
+	cb.ctx.layout().append(section, cb.lines)
+}
@@ -0,0 +1,85 @@
+package ezproto
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSections(t *testing.T) {
+	tests := []struct {
+		name     string
+		generate func(ctx *Context)
+		want     string
+	}{
+		{
+			name: "canonical order",
+			generate: func(ctx *Context) {
+				ctx.Section(SectionInit).Line("func init() {}").Generate()
+				ctx.Section(SectionFunctions).Line("func F() {}").Generate()
+				ctx.Section(SectionTypes).Line("type T int").Generate()
+				ctx.Section(SectionConstants).Line("const C = 1").Generate()
+				ctx.Section(SectionHeader).Line("package x").Generate()
+			},
+			want: "package x\n\nconst C = 1\n\ntype T int\n\nfunc F() {}\n\nfunc init() {}\n",
+		},
+		{
+			name: "appended in order",
+			generate: func(ctx *Context) {
+				ctx.Section(SectionHeader).Line("package x").Generate()
+				ctx.Section(SectionTypes).Line("type A int").Generate()
+				ctx.Code().Line("type B int").GenerateIn(SectionTypes)
+			},
+			want: "package x\n\ntype A int\ntype B int\n",
+		},
+		{
+			name: "plain code first",
+			generate: func(ctx *Context) {
+				ctx.Section(SectionTypes).Line("type T int").Generate()
+				ctx.Code().Package("x").Generate()
+			},
+			want: "package x\n\ntype T int\n",
+		},
+		{
+			name: "anchors",
+			generate: func(ctx *Context) {
+				ctx.DefineSection("validators", SectionTypes)
+				ctx.DefineSection("validators", SectionInit)
+				ctx.Section("extra").Line("var Extra = 1").Generate()
+				ctx.Section("validators").Line("func (T) Validate() error { return nil }").Generate()
+				ctx.Section(SectionFunctions).Line("func F() {}").Generate()
+				ctx.Section(SectionTypes).Line("type T int").Generate()
+				ctx.Section(SectionHeader).Line("package x").Generate()
+			},
+			want: "package x\n\ntype T int\n\nfunc (T) Validate() error { return nil }\n\nfunc F() {}\n\nvar Extra = 1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := func(concurrency int) *Plugin {
+				return NewPlugin().WithOptions(Options{Concurrency: concurrency}).
+					GenerateFor("**", func(ctx *Context, _ *File) error {
+						tt.generate(ctx)
+
+						return nil
+					})
+			}
+
+			resp, err := p(0).Generate(testRequest(""))
+			if err != nil || resp.Error != nil {
+				t.Fatalf("Generate() = %v, %v", resp.GetError(), err)
+			}
+
+			if got := resp.GetFile()[3].GetContent(); got != tt.want {
+				t.Errorf("generated %q, want %q", got, tt.want)
+			}
+
+			// Workers assemble sections the same way
+			parallel, err := p(4).Generate(testRequest(""))
+			if err != nil || !proto.Equal(parallel, resp) {
+				t.Errorf("parallel Generate() = %v, %v, want %v", parallel, err, resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,365 @@
+package ezproto
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Selector decides which proto files a generator runs for.
+type Selector interface {
+	Match(file *File) bool
+}
+
+// SelectorFunc adapts a predicate to the Selector interface.
+type SelectorFunc func(file *File) bool
+
+// Match calls f(file).
+func (f SelectorFunc) Match(file *File) bool {
+	return f(file)
+}
+
+// ErrInvalidPattern is returned for malformed selector patterns.
+var ErrInvalidPattern = errors.New("invalid pattern")
+
+// Select builds a selector from file patterns.
+//
+// A file is selected when it matches at least one include pattern, or when
+// all patterns are excludes, and matches no exclude pattern. Patterns are:
+//
+//	api/**/*.proto          glob on the file path
+//	*.proto                 glob without '/' matches the base name at any depth
+//	re:^api/v[0-9]+/        regular expression on the file path
+//	package:acme.orders.*   glob on the proto package
+//	option:go_package       file option is set
+//	option:(acme.gen)=true  file option, including extensions, has a value
+//	!pattern                excludes files matching pattern
+//
+// In path globs '*' and '?' do not cross '/', and a "**" segment matches zero
+// or more directories. In package globs '*' matches a single package
+// component and "**" matches zero or more components.
+func Select(patterns ...string) (Selector, error) {
+	set, err := parsePatterns(patterns, parsePattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return SelectorFunc(set.match), nil
+}
+
+// patternSet combines include and exclude matchers.
+type patternSet[T any] struct {
+	includes []func(T) bool
+	excludes []func(T) bool
+}
+
+// parsePatterns parses patterns, treating a leading '!' as an exclude.
+func parsePatterns[T any](patterns []string, parse func(string) (func(T) bool, error)) (*patternSet[T], error) {
+	set := &patternSet[T]{}
+
+	for _, pattern := range patterns {
+		exclude := strings.HasPrefix(pattern, "!")
+
+		m, err := parse(strings.TrimPrefix(pattern, "!"))
+		if err != nil {
+			return nil, err
+		}
+
+		if exclude {
+			set.excludes = append(set.excludes, m)
+		} else {
+			set.includes = append(set.includes, m)
+		}
+	}
+
+	return set, nil
+}
+
+// match reports whether v matches an include, or there are none, and no exclude.
+func (s *patternSet[T]) match(v T) bool {
+	for _, m := range s.excludes {
+		if m(v) {
+			return false
+		}
+	}
+
+	if len(s.includes) == 0 {
+		return true
+	}
+
+	for _, m := range s.includes {
+		if m(v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parsePattern parses a single pattern without its exclude prefix.
+func parsePattern(pattern string) (func(*File) bool, error) {
+	kind, value, ok := strings.Cut(pattern, ":")
+	if !ok {
+		return globSelector(pattern)
+	}
+
+	switch kind {
+	case "re":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidPattern, pattern, err)
+		}
+
+		return func(file *File) bool {
+			return re.MatchString(file.Name)
+		}, nil
+	case "package":
+		glob, err := newNameGlob(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidPattern, pattern, err)
+		}
+
+		return func(file *File) bool {
+			return glob.match(file.ProtoPackage())
+		}, nil
+	case "option":
+		opt, err := newOptionMatcher(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidPattern, pattern, err)
+		}
+
+		return func(file *File) bool {
+			return opt.match(file.model.options(file.proto.Desc.Options()))
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w %q: unknown pattern kind %q", ErrInvalidPattern, pattern, kind)
+	}
+}
+
+// selectElements builds a matcher for elements from the patterns described
+// in Plugin.ForEachMessage.
+func selectElements(patterns ...string) (func(Element) bool, error) {
+	set, err := parsePatterns(patterns, parseElementPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return set.match, nil
+}
+
+// parseElementPattern parses a single element pattern without its exclude prefix.
+func parseElementPattern(pattern string) (func(Element) bool, error) {
+	kind, value, ok := strings.Cut(pattern, ":")
+	if !ok {
+		return elementGlob(pattern)
+	}
+
+	switch kind {
+	case "re":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidPattern, pattern, err)
+		}
+
+		return func(el Element) bool {
+			return re.MatchString(el.FullName())
+		}, nil
+	case "option":
+		opt, err := newOptionMatcher(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidPattern, pattern, err)
+		}
+
+		return func(el Element) bool {
+			return opt.match(elementOptions(el))
+		}, nil
+	case "file":
+		match, err := parsePattern(value)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(el Element) bool {
+			return match(el.File())
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w %q: unknown pattern kind %q", ErrInvalidPattern, pattern, kind)
+	}
+}
+
+// elementGlob matches full names, or short names if the pattern has no '.'.
+func elementGlob(pattern string) (func(Element) bool, error) {
+	glob, err := newNameGlob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidPattern, pattern, err)
+	}
+
+	if len(glob.segments) == 1 {
+		return func(el Element) bool {
+			name := el.FullName()
+
+			return glob.match(name[strings.LastIndex(name, ".")+1:])
+		}, nil
+	}
+
+	return func(el Element) bool {
+		return glob.match(el.FullName())
+	}, nil
+}
+
+// globSelector matches file paths against a glob supporting "**".
+func globSelector(pattern string) (func(*File) bool, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("%w: empty pattern", ErrInvalidPattern)
+	}
+
+	segments := strings.Split(pattern, "/")
+	for _, seg := range segments {
+		if _, err := path.Match(seg, ""); err != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidPattern, pattern, err)
+		}
+	}
+
+	// Like .gitignore, a pattern without a slash matches the base name at any depth
+	if len(segments) == 1 {
+		return func(file *File) bool {
+			matched, _ := path.Match(pattern, path.Base(file.Name))
+
+			return matched
+		}, nil
+	}
+
+	return func(file *File) bool {
+		return matchSegments(segments, strings.Split(file.Name, "/"))
+	}, nil
+}
+
+// matchSegments matches name segments against pattern segments, where a "**"
+// pattern segment matches zero or more name segments.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// nameGlob matches dot-separated protobuf names such as packages and full names.
+type nameGlob struct {
+	segments []string
+}
+
+func newNameGlob(pattern string) (*nameGlob, error) {
+	if pattern == "" {
+		return nil, errors.New("empty name")
+	}
+
+	segments := strings.Split(pattern, ".")
+	for _, seg := range segments {
+		if seg == "" {
+			return nil, errors.New("empty name component")
+		}
+
+		if _, err := path.Match(seg, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	return &nameGlob{segments: segments}, nil
+}
+
+func (g *nameGlob) match(name string) bool {
+	var parts []string
+	if name != "" {
+		parts = strings.Split(name, ".")
+	}
+
+	return matchSegments(g.segments, parts)
+}
+
+// optionMatcher matches an options message by field or extension name and value.
+type optionMatcher struct {
+	name     string
+	value    string
+	hasValue bool
+}
+
+// newOptionMatcher parses "name", "name=value", "(ext.name)" or "(ext.name)=value".
+func newOptionMatcher(pattern string) (*optionMatcher, error) {
+	name, value, hasValue := strings.Cut(pattern, "=")
+	if name == "" {
+		return nil, errors.New("empty option name")
+	}
+
+	if strings.HasPrefix(name, "(") != strings.HasSuffix(name, ")") {
+		return nil, fmt.Errorf("unbalanced parentheses in option %q", name)
+	}
+
+	return &optionMatcher{
+		name:     name,
+		value:    value,
+		hasValue: hasValue,
+	}, nil
+}
+
+func (o *optionMatcher) match(opts protoreflect.Message) bool {
+	if opts == nil {
+		return false
+	}
+
+	found := false
+
+	opts.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if optionName(fd) != o.name {
+			return true
+		}
+
+		found = !o.hasValue || formatOptionValue(fd, v) == o.value
+
+		return false
+	})
+
+	return found
+}
+
+// optionName returns the name of an option as written in a .proto file.
+func optionName(fd protoreflect.FieldDescriptor) string {
+	if fd.IsExtension() {
+		return "(" + string(fd.FullName()) + ")"
+	}
+
+	return string(fd.Name())
+}
+
+// formatOptionValue formats an option value as written in a .proto file.
+func formatOptionValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	if fd.Kind() == protoreflect.EnumKind && !fd.IsList() {
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+	}
+
+	return v.String()
+}
@@ -0,0 +1,255 @@
+package ezproto
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// Field numbers of the custom options declared in acme/options.proto.
+const (
+	testGenOption   = 50000
+	testTableOption = 50001
+)
+
+// testFiles returns the files of the test request, built by hand so that
+// tests do not need protoc:
+//
+//	acme/options.proto     package acme, declares (acme.gen) and (acme.table)
+//	api/v1/orders.proto    package acme.orders.v1, option (acme.gen) = true
+//	api/v2/users.proto     package acme.users.v2, imports orders
+//	internal/x.proto       package internal
+func testFiles() []*descriptorpb.FileDescriptorProto {
+	options := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("acme/options.proto"),
+		Package:    proto.String("acme"),
+		Dependency: []string{"google/protobuf/descriptor.proto"},
+		Options:    &descriptorpb.FileOptions{GoPackage: proto.String("example.com/acme;acme")},
+		Extension: []*descriptorpb.FieldDescriptorProto{
+			testExtension("gen", ".google.protobuf.FileOptions", testGenOption),
+			testExtension("table", ".google.protobuf.MessageOptions", testTableOption),
+		},
+		Syntax: proto.String("proto3"),
+	}
+
+	orderOptions := &descriptorpb.MessageOptions{}
+	setTestOption(orderOptions, testTableOption)
+
+	orders := testFile("api/v1/orders.proto", "acme.orders.v1", "example.com/api/v1/orders;orders",
+		testMessage("Order",
+			testMessage("Item"),
+		),
+		testMessage("OrderRequest"),
+	)
+	orders.Dependency = []string{"acme/options.proto"}
+	orders.MessageType[0].Options = orderOptions
+	orders.MessageType[0].EnumType = []*descriptorpb.EnumDescriptorProto{testEnum("State")}
+	orders.EnumType = []*descriptorpb.EnumDescriptorProto{testEnum("Status")}
+	orders.Service = []*descriptorpb.ServiceDescriptorProto{{
+		Name: proto.String("OrderService"),
+		Method: []*descriptorpb.MethodDescriptorProto{{
+			Name:       proto.String("GetOrder"),
+			InputType:  proto.String(".acme.orders.v1.OrderRequest"),
+			OutputType: proto.String(".acme.orders.v1.Order"),
+		}},
+	}}
+	setTestOption(orders.Options, testGenOption)
+
+	users := testFile("api/v2/users.proto", "acme.users.v2", "example.com/api/v2/users;users",
+		testMessage("User"),
+		testMessage("UserRequest"),
+	)
+	users.Dependency = []string{"api/v1/orders.proto"}
+	users.MessageType[0].Options = &descriptorpb.MessageOptions{Deprecated: proto.Bool(true)}
+
+	internal := testFile("internal/x.proto", "internal", "example.com/internal/x", testMessage("X"))
+
+	return []*descriptorpb.FileDescriptorProto{
+		protodesc.ToFileDescriptorProto(descriptorpb.File_google_protobuf_descriptor_proto),
+		options,
+		orders,
+		users,
+		internal,
+	}
+}
+
+func testFile(name, pkg, goPackage string, messages ...*descriptorpb.DescriptorProto) *descriptorpb.FileDescriptorProto {
+	return &descriptorpb.FileDescriptorProto{
+		Name:        proto.String(name),
+		Package:     proto.String(pkg),
+		Options:     &descriptorpb.FileOptions{GoPackage: proto.String(goPackage)},
+		MessageType: messages,
+		Syntax:      proto.String("proto3"),
+	}
+}
+
+func testMessage(name string, nested ...*descriptorpb.DescriptorProto) *descriptorpb.DescriptorProto {
+	return &descriptorpb.DescriptorProto{
+		Name:       proto.String(name),
+		NestedType: nested,
+		Field: []*descriptorpb.FieldDescriptorProto{{
+			Name:     proto.String("id"),
+			JsonName: proto.String("id"),
+			Number:   proto.Int32(1),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+		}},
+	}
+}
+
+func testEnum(name string) *descriptorpb.EnumDescriptorProto {
+	return &descriptorpb.EnumDescriptorProto{
+		Name: proto.String(name),
+		Value: []*descriptorpb.EnumValueDescriptorProto{{
+			Name:   proto.String(name + "_UNSPECIFIED"),
+			Number: proto.Int32(0),
+		}},
+	}
+}
+
+func testExtension(name, extendee string, number int32) *descriptorpb.FieldDescriptorProto {
+	return &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Number:   proto.Int32(number),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     descriptorpb.FieldDescriptorProto_TYPE_BOOL.Enum(),
+		Extendee: proto.String(extendee),
+	}
+}
+
+// setTestOption sets a custom bool option to true the way protoc encodes
+// it, as a field unknown to the options message.
+func setTestOption(opts proto.Message, number protowire.Number) {
+	b := protowire.AppendTag(nil, number, protowire.VarintType)
+	b = protowire.AppendVarint(b, 1)
+
+	m := opts.ProtoReflect()
+	m.SetUnknown(append(m.GetUnknown(), b...))
+}
+
+// testRequest returns a request generating every file of testFiles except
+// the imported descriptor.proto.
+func testRequest(param string) *pluginpb.CodeGeneratorRequest {
+	files := testFiles()
+
+	req := &pluginpb.CodeGeneratorRequest{
+		ProtoFile: files,
+		Parameter: proto.String(param),
+	}
+
+	for _, f := range files[1:] {
+		req.FileToGenerate = append(req.FileToGenerate, f.GetName())
+	}
+
+	return req
+}
+
+// testModel returns the model of the files generated for testRequest.
+func testModel(t *testing.T) []*File {
+	t.Helper()
+
+	gen, err := protogen.Options{}.New(testRequest(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newModel(gen).generated()
+}
+
+func TestSelect(t *testing.T) {
+	files := testModel(t)
+
+	tests := []struct {
+		patterns []string
+		want     []string
+	}{
+		{[]string{"api/**/*.proto"}, []string{"api/v1/orders.proto", "api/v2/users.proto"}},
+		{[]string{"**/*.proto"}, []string{"acme/options.proto", "api/v1/orders.proto", "api/v2/users.proto", "internal/x.proto"}},
+		{[]string{"api/*.proto"}, nil},
+		{[]string{"users.proto"}, []string{"api/v2/users.proto"}},
+		{[]string{"*.proto", "!internal/**"}, []string{"acme/options.proto", "api/v1/orders.proto", "api/v2/users.proto"}},
+		{[]string{"!api/**"}, []string{"acme/options.proto", "internal/x.proto"}},
+		{[]string{`re:^api/v[0-9]+/`}, []string{"api/v1/orders.proto", "api/v2/users.proto"}},
+		{[]string{"package:acme.*.*"}, []string{"api/v1/orders.proto", "api/v2/users.proto"}},
+		{[]string{"package:acme.**"}, []string{"acme/options.proto", "api/v1/orders.proto", "api/v2/users.proto"}},
+		{[]string{"package:acme.orders"}, nil},
+		{[]string{"option:go_package"}, []string{"acme/options.proto", "api/v1/orders.proto", "api/v2/users.proto", "internal/x.proto"}},
+		{[]string{"option:go_package=example.com/internal/x"}, []string{"internal/x.proto"}},
+		{[]string{"option:(acme.gen)"}, []string{"api/v1/orders.proto"}},
+		{[]string{"option:(acme.gen)=true", "internal/*"}, []string{"api/v1/orders.proto", "internal/x.proto"}},
+		{[]string{"option:(acme.gen)=false"}, nil},
+	}
+
+	for _, tt := range tests {
+		sel, err := Select(tt.patterns...)
+		if err != nil {
+			t.Fatalf("Select(%q) error = %v", tt.patterns, err)
+		}
+
+		var got []string
+
+		for _, f := range files {
+			if sel.Match(f) {
+				got = append(got, f.Name)
+			}
+		}
+
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Select(%q) matched %q, want %q", tt.patterns, got, tt.want)
+		}
+	}
+}
+
+func TestSelectInvalidPattern(t *testing.T) {
+	for _, pattern := range []string{
+		"",
+		"api/[.proto",
+		"re:(",
+		"package:",
+		"package:acme..orders",
+		"option:",
+		"option:(acme.gen",
+		"kind:value",
+	} {
+		if _, err := Select(pattern); !errors.Is(err, ErrInvalidPattern) {
+			t.Errorf("Select(%q) error = %v, want %v", pattern, err, ErrInvalidPattern)
+		}
+	}
+}
+
+func TestGenerateFor(t *testing.T) {
+	var got []string
+
+	record := func(_ *Context, file *File) error {
+		got = append(got, file.Name)
+
+		return nil
+	}
+
+	resp, err := NewPlugin().
+		GenerateFor("api/**", record).
+		GenerateWhen(func(file *File) bool { return file.ProtoPackage() == "internal" }, record).
+		Generate(testRequest(""))
+	if err != nil || resp.Error != nil {
+		t.Fatalf("Generate() = %v, %v", resp.GetError(), err)
+	}
+
+	// Generators run in registration order, each over the files in order
+	want := []string{"api/v1/orders.proto", "api/v2/users.proto", "internal/x.proto"}
+	if !slices.Equal(got, want) {
+		t.Errorf("generators ran for %q, want %q", got, want)
+	}
+
+	resp, err = NewPlugin().GenerateFor("re:(", record).Generate(testRequest(""))
+	if err != nil || resp.GetError() == "" {
+		t.Errorf("Generate() with a malformed pattern = %q, %v, want an error response", resp.GetError(), err)
+	}
+}
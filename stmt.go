@@ -0,0 +1,196 @@
+package ezproto
+
+import (
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// Stmt is a Go statement built from typed values. Like Expr, statements
+// are reusable values that are qualified for the file they are rendered into.
+type Stmt struct {
+	build func(q qualifier) ast.Stmt
+}
+
+func (s Stmt) node(q qualifier) ast.Node {
+	return s.ast(q)
+}
+
+func (s Stmt) ast(q qualifier) ast.Stmt {
+	if s.build == nil {
+		return &ast.EmptyStmt{Implicit: true}
+	}
+
+	return s.build(q)
+}
+
+// block builds a block statement from a list of statements.
+func block(q qualifier, body []Stmt) *ast.BlockStmt {
+	list := make([]ast.Stmt, len(body))
+	for i, s := range body {
+		list[i] = s.ast(q)
+	}
+
+	return &ast.BlockStmt{List: list}
+}
+
+// StmtFromAST wraps a go/ast statement, e.g. one returned by
+// CodeBuilder.AST and transformed. The node is copied immediately, so
+// later changes to it have no effect.
+func StmtFromAST(s ast.Stmt) Stmt {
+	src := render(s)
+
+	if parseStmt(src) == nil {
+		return Stmt{func(qualifier) ast.Stmt { return &ast.ExprStmt{X: ast.NewIdent(src)} }}
+	}
+
+	// Parse on every build, so that transforming one AST leaves others intact
+	return Stmt{func(qualifier) ast.Stmt { return parseStmt(src) }}
+}
+
+// parseStmt parses a single statement, or returns nil if src is not one.
+func parseStmt(src string) ast.Stmt {
+	x, err := parser.ParseExpr("func() {\n" + src + "\n}")
+	if err != nil {
+		return nil
+	}
+
+	if body := x.(*ast.FuncLit).Body.List; len(body) == 1 {
+		return body[0]
+	}
+
+	return nil
+}
+
+// ExprStmt returns a statement evaluating e, such as a call.
+func ExprStmt(e Expr) Stmt {
+	return Stmt{func(q qualifier) ast.Stmt { return &ast.ExprStmt{X: e.ast(q)} }}
+}
+
+// AssignStmt returns the assignment lhs = rhs.
+func AssignStmt(lhs, rhs []Expr) Stmt {
+	return assign(lhs, token.ASSIGN, rhs)
+}
+
+// DefineStmt returns the short variable declaration names := rhs.
+func DefineStmt(names []string, rhs ...Expr) Stmt {
+	lhs := make([]Expr, len(names))
+	for i, name := range names {
+		lhs[i] = Id(name)
+	}
+
+	return assign(lhs, token.DEFINE, rhs)
+}
+
+// OpAssignStmt returns the assignment lhs op= rhs, such as lhs += rhs.
+// It panics if op is not a Go binary operator.
+func OpAssignStmt(lhs Expr, op string, rhs Expr) Stmt {
+	tok, ok := assignOperators[op]
+	if !ok {
+		panic(fmt.Sprintf("ezproto.OpAssignStmt: unknown operator %q", op))
+	}
+
+	return assign([]Expr{lhs}, tok, []Expr{rhs})
+}
+
+func assign(lhs []Expr, tok token.Token, rhs []Expr) Stmt {
+	return Stmt{func(q qualifier) ast.Stmt {
+		return &ast.AssignStmt{Lhs: exprs(q, lhs), Tok: tok, Rhs: exprs(q, rhs)}
+	}}
+}
+
+// assignOperators maps binary operators to their assignment forms.
+var assignOperators = map[string]token.Token{
+	"+": token.ADD_ASSIGN, "-": token.SUB_ASSIGN, "*": token.MUL_ASSIGN, "/": token.QUO_ASSIGN,
+	"%": token.REM_ASSIGN, "&": token.AND_ASSIGN, "|": token.OR_ASSIGN, "^": token.XOR_ASSIGN,
+	"<<": token.SHL_ASSIGN, ">>": token.SHR_ASSIGN, "&^": token.AND_NOT_ASSIGN,
+}
+
+// IncStmt returns e++.
+func IncStmt(e Expr) Stmt {
+	return Stmt{func(q qualifier) ast.Stmt { return &ast.IncDecStmt{X: e.ast(q), Tok: token.INC} }}
+}
+
+// DecStmt returns e--.
+func DecStmt(e Expr) Stmt {
+	return Stmt{func(q qualifier) ast.Stmt { return &ast.IncDecStmt{X: e.ast(q), Tok: token.DEC} }}
+}
+
+// ReturnStmt returns a return statement with optional results.
+func ReturnStmt(results ...Expr) Stmt {
+	return Stmt{func(q qualifier) ast.Stmt { return &ast.ReturnStmt{Results: exprs(q, results)} }}
+}
+
+// IfStmt returns if cond { body }. Use Else to add an else branch.
+func IfStmt(cond Expr, body ...Stmt) Stmt {
+	return Stmt{func(q qualifier) ast.Stmt {
+		return &ast.IfStmt{Cond: cond.ast(q), Body: block(q, body)}
+	}}
+}
+
+// Else adds an else branch to an if statement. Passing a single IfStmt
+// produces an else-if chain. It panics if s is not an if statement.
+func (s Stmt) Else(body ...Stmt) Stmt {
+	return Stmt{func(q qualifier) ast.Stmt {
+		ifStmt, ok := s.ast(q).(*ast.IfStmt)
+		if !ok {
+			panic("ezproto.Stmt.Else: not an if statement")
+		}
+
+		// Attach to the innermost else-if so that chains can be extended
+		last := ifStmt
+		for next, ok := last.Else.(*ast.IfStmt); ok; next, ok = last.Else.(*ast.IfStmt) {
+			last = next
+		}
+
+		if len(body) == 1 {
+			if elseIf, ok := body[0].ast(q).(*ast.IfStmt); ok {
+				last.Else = elseIf
+
+				return ifStmt
+			}
+		}
+
+		last.Else = block(q, body)
+
+		return ifStmt
+	}}
+}
+
+// ForRangeStmt returns for key, value := range x { body }. Empty names are
+// omitted, or replaced with _ when only the value is wanted.
+func ForRangeStmt(key, value string, x Expr, body ...Stmt) Stmt {
+	return Stmt{func(q qualifier) ast.Stmt {
+		r := &ast.RangeStmt{X: x.ast(q), Body: block(q, body)}
+
+		if key != "" || value != "" {
+			r.Tok = token.DEFINE
+			r.Key = ast.NewIdent(cmp.Or(key, "_"))
+		}
+
+		if value != "" {
+			r.Value = ast.NewIdent(value)
+		}
+
+		return r
+	}}
+}
+
+// BlockStmt returns the statements enclosed in braces.
+func BlockStmt(body ...Stmt) Stmt {
+	return Stmt{func(q qualifier) ast.Stmt { return block(q, body) }}
+}
+
+// Stmt writes typed statements at the current indentation.
+// The content of multi-line raw strings is written as is.
+func (cb *CodeBuilder) Stmt(stmts ...Stmt) *CodeBuilder {
+	for _, s := range stmts {
+		src := renderIndented(s.node(cb.qualifier()), cb.indent)
+		cb.lines = append(cb.lines, strings.Split(src, "\n")...)
+	}
+
+	return cb
+}
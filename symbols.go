@@ -0,0 +1,373 @@
+package ezproto
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"go/token"
+	"go/types"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// DeclarationError reports an identifier declared twice in the same Go
+// package, naming both declarations.
+type DeclarationError struct {
+	// Name is the identifier.
+	Name string
+	// ImportPath is the Go package both declarations belong to.
+	ImportPath protogen.GoImportPath
+	// First and Second describe the declarations in file order, such as
+	// generator "*.proto" (acme/orders.proto). Names generated by
+	// protoc-gen-go are attributed to protoc-gen-go.
+	First, Second string
+}
+
+func (e *DeclarationError) Error() string {
+	return fmt.Sprintf("%s is declared twice in package %s: by %s and by %s", e.Name, e.ImportPath, e.First, e.Second)
+}
+
+// declaration is a package-level identifier claimed by a generator.
+type declaration struct {
+	by    string
+	order int
+	seq   int
+	// once writes the code of a DeclareOnce declaration, nil for Declare.
+	// It runs only for the declaration that wins, in a copy of the
+	// declaring context.
+	once func(*CodeBuilder)
+	ctx  *Context
+}
+
+// packageSymbols is the symbol table of one Go package.
+type packageSymbols struct {
+	// generated maps the identifiers protoc-gen-go declares in the package
+	// to the file declaring them.
+	generated map[string]string
+	declared  map[string][]*declaration
+}
+
+// symbolTable tracks package-level declarations across the files of a run.
+// Generators may run in parallel, so which declaration of a DeclareOnce key
+// is emitted and which collisions are reported is decided by resolve, in
+// file order, once all of them are done.
+type symbolTable struct {
+	mu       sync.Mutex
+	gen      *protogen.Plugin
+	seq      int
+	packages map[protogen.GoImportPath]*packageSymbols
+	files    map[GeneratedFile]map[string]bool
+}
+
+func newSymbolTable(gen *protogen.Plugin) *symbolTable {
+	return &symbolTable{
+		gen:      gen,
+		packages: make(map[protogen.GoImportPath]*packageSymbols),
+		files:    make(map[GeneratedFile]map[string]bool),
+	}
+}
+
+// pkg returns the symbol table of a package, collecting the names
+// protoc-gen-go generates for its files on first use.
+func (t *symbolTable) pkg(importPath protogen.GoImportPath) *packageSymbols {
+	if ps, ok := t.packages[importPath]; ok {
+		return ps
+	}
+
+	ps := &packageSymbols{
+		generated: make(map[string]string),
+		declared:  make(map[string][]*declaration),
+	}
+
+	for _, f := range t.gen.Files {
+		if f.GoImportPath != importPath {
+			continue
+		}
+
+		path := f.Desc.Path()
+		ps.generated[f.GoDescriptorIdent.GoName] = path
+
+		// Unexported variables and functions holding the file descriptor
+		prefix := "file_" + strings.TrimPrefix(f.GoDescriptorIdent.GoName, "File_")
+		for _, suffix := range descriptorVars {
+			ps.generated[prefix+suffix] = path
+		}
+
+		for _, e := range f.Extensions {
+			ps.generated["E_"+e.GoIdent.GoName] = path
+		}
+
+		collectGenerated(ps.generated, path, f.Messages, f.Enums)
+	}
+
+	t.packages[importPath] = ps
+
+	return ps
+}
+
+// descriptorVars are the suffixes of the unexported file-level identifiers
+// protoc-gen-go declares for every file.
+var descriptorVars = []string{
+	"_rawDesc", "_rawDescOnce", "_rawDescData", "_rawDescGZIP", "_init",
+	"_goTypes", "_depIdxs", "_enumTypes", "_msgTypes", "_extTypes",
+}
+
+// collectGenerated adds the identifiers protoc-gen-go declares for messages
+// and enums, including nested ones, extensions, proto2 default values, oneof
+// wrapper types and the interfaces they implement. Wrapper names come from
+// protogen, which already suffixes them with _ when they clash with a nested
+// message or enum.
+func collectGenerated(names map[string]string, path string, messages []*protogen.Message, enums []*protogen.Enum) {
+	for _, e := range enums {
+		names[e.GoIdent.GoName] = path
+		names[e.GoIdent.GoName+"_name"] = path
+		names[e.GoIdent.GoName+"_value"] = path
+
+		for _, v := range e.Values {
+			names[v.GoIdent.GoName] = path
+		}
+	}
+
+	for _, m := range messages {
+		names[m.GoIdent.GoName] = path
+
+		for _, e := range m.Extensions {
+			names["E_"+e.GoIdent.GoName] = path
+		}
+
+		for _, o := range m.Oneofs {
+			if !o.Desc.IsSynthetic() {
+				names["is"+m.GoIdent.GoName+"_"+o.GoName] = path
+			}
+		}
+
+		for _, f := range m.Fields {
+			if f.Oneof != nil && !f.Oneof.Desc.IsSynthetic() {
+				names[f.GoIdent.GoName] = path
+			}
+
+			if f.Desc.HasDefault() {
+				names["Default_"+m.GoIdent.GoName+"_"+f.GoName] = path
+			}
+		}
+
+		collectGenerated(names, path, m.Messages, m.Enums)
+	}
+}
+
+// reserved reports whether name is declared by protoc-gen-go in the package,
+// and for which file.
+func (ps *packageSymbols) reserved(name string) (string, bool) {
+	path, ok := ps.generated[name]
+
+	return path, ok
+}
+
+// declare records a declaration of name in the package and in its file.
+func (t *symbolTable) declare(c *Context, name string, d *declaration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.seq++
+	d.by, d.order, d.seq = c.declarer(), c.order, t.seq
+
+	ps := t.pkg(c.importPath)
+	ps.declared[name] = append(ps.declared[name], d)
+	t.fileNames(c.output)[name] = true
+}
+
+func (t *symbolTable) fileNames(output GeneratedFile) map[string]bool {
+	names, ok := t.files[output]
+	if !ok {
+		names = make(map[string]bool)
+		t.files[output] = names
+	}
+
+	return names
+}
+
+// resolve emits the first declaration of every DeclareOnce key into its
+// file and reports identifiers declared more than once, in file order.
+func (t *symbolTable) resolve() error {
+	var (
+		errs []error
+		once []*declaration
+	)
+
+	t.mu.Lock()
+
+	for _, importPath := range slices.Sorted(maps.Keys(t.packages)) {
+		ps := t.packages[importPath]
+
+		for _, name := range slices.Sorted(maps.Keys(ps.declared)) {
+			d, err := ps.resolve(importPath, name)
+
+			switch {
+			case err != nil:
+				errs = append(errs, err)
+			case d.once != nil:
+				once = append(once, d)
+			}
+		}
+	}
+
+	t.mu.Unlock()
+
+	// The code is written without the lock, as it may allocate names
+	for _, d := range once {
+		errs = append(errs, d.emit())
+	}
+
+	return errors.Join(errs...)
+}
+
+// resolve returns the declaration of name that wins, or an error if name is
+// declared more than once.
+func (ps *packageSymbols) resolve(importPath protogen.GoImportPath, name string) (*declaration, error) {
+	decls := ps.declared[name]
+	delete(ps.declared, name)
+
+	slices.SortFunc(decls, func(a, b *declaration) int {
+		return cmp.Or(cmp.Compare(a.order, b.order), cmp.Compare(a.seq, b.seq))
+	})
+
+	if path, ok := ps.reserved(name); ok {
+		return nil, &DeclarationError{Name: name, ImportPath: importPath, First: "protoc-gen-go (" + path + ")", Second: decls[0].by}
+	}
+
+	// Repeated DeclareOnce calls are expected; anything else is a collision
+	first := decls[0]
+	for _, d := range decls[1:] {
+		if d.once == nil || first.once == nil {
+			return nil, &DeclarationError{Name: name, ImportPath: importPath, First: first.by, Second: d.by}
+		}
+	}
+
+	return first, nil
+}
+
+// emit writes the code of a DeclareOnce declaration into the
+// SectionFunctions of its file, so that only that file imports what the
+// code uses.
+func (d *declaration) emit() error {
+	cb := d.ctx.NewCodeBuilder()
+
+	err := d.ctx.plugin.protect(d.ctx, fmt.Sprintf("generator %q", d.ctx.generator), func() error {
+		d.once(cb)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	cb.GenerateIn(SectionFunctions)
+
+	return nil
+}
+
+// declarer describes the generator running in the context for errors.
+func (c *Context) declarer() string {
+	by := "unnamed generator"
+	if c.generator != "" {
+		by = "generator " + strconv.Quote(c.generator)
+	}
+
+	if c.file != nil {
+		by += " (" + c.file.Desc.Path() + ")"
+	}
+
+	return by
+}
+
+// symbols returns the symbol table of the run, creating the output file
+// that declarations are attributed to.
+func (c *Context) symbols() *symbolTable {
+	if c.output == nil {
+		c.createOutputFile()
+	}
+
+	return c.plugin.symbols
+}
+
+// Declare records that the current generator declares the package-level
+// identifier name in the output file's Go package. Once all generators have
+// run, names declared more than once in a package, or also declared by
+// protoc-gen-go, fail generation with a *DeclarationError naming both.
+func (c *Context) Declare(name string) {
+	c.symbols().declare(c, name, &declaration{})
+}
+
+// DeclareOnce declares a package-level helper, such as a shared ptr[T]
+// function or errInvalid variable, that several generators or files may
+// need. key is the identifier it declares. Once all generators have run,
+// fn is called for the first file in the package that asks for key and its
+// code is emitted into that file's SectionFunctions; the calls of other
+// files are discarded. Like Declare, key must not collide with other
+// declarations in the package.
+func (c *Context) DeclareOnce(key string, fn func(*CodeBuilder)) {
+	t := c.symbols()
+
+	// fn runs later, after the generator has returned
+	ctx := *c
+	t.declare(c, key, &declaration{once: fn, ctx: &ctx})
+}
+
+// AllocateName returns an identifier based on base that is safe to declare
+// in the output file: not a Go keyword or predeclared identifier, not a
+// name protoc-gen-go generates in the same package, not declared in the
+// package by any file so far, and not allocated in the same file before.
+// Characters that cannot appear in an identifier are replaced and a numeric
+// suffix is added when needed, so AllocateName("string") returns "string1".
+// The name is reserved for the file but not declared in the package; use
+// Declare or DeclareOnce for identifiers shared across files.
+func (c *Context) AllocateName(base string) string {
+	t := c.symbols()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ps := t.pkg(c.importPath)
+	used := t.fileNames(c.output)
+
+	name := identifier(base)
+	for i, orig := 1, name; !allocatable(name, ps, used); i++ {
+		name = orig + strconv.Itoa(i)
+	}
+
+	used[name] = true
+
+	return name
+}
+
+func allocatable(name string, ps *packageSymbols, used map[string]bool) bool {
+	if _, ok := ps.reserved(name); ok || len(ps.declared[name]) > 0 {
+		return false
+	}
+
+	return name != "_" && !used[name] && !token.IsKeyword(name) && types.Universe.Lookup(name) == nil
+}
+
+// identifier turns s into a valid Go identifier.
+func identifier(s string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+
+		return '_'
+	}, s)
+
+	if r, _ := utf8.DecodeRuneInString(name); !unicode.IsLetter(r) && r != '_' {
+		return "_" + name
+	}
+
+	return name
+}
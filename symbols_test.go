@@ -0,0 +1,286 @@
+package ezproto
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// samePackage maps users.proto into the Go package of orders.proto.
+const samePackage = "Mapi/v2/users.proto=example.com/api/v1/orders;orders"
+
+func TestDeclareOnce(t *testing.T) {
+	var calls []string
+
+	p := func(concurrency int) *Plugin {
+		calls = nil
+
+		return NewPlugin().WithOptions(Options{Concurrency: concurrency}).
+			GenerateFor("api/**", func(ctx *Context, file *File) error {
+				ctx.Code().Package("orders").Generate()
+				ctx.DeclareOnce("upper", func(cb *CodeBuilder) {
+					calls = append(calls, file.Name)
+					cb.Line("func upper(s string) string { return %s(s) }", cb.Qualify(Ident("strings", "ToUpper")))
+				})
+
+				return nil
+			})
+	}
+
+	resp, err := p(0).Generate(testRequest(samePackage))
+	if err != nil || resp.Error != nil {
+		t.Fatalf("Generate() = %v, %v", resp.GetError(), err)
+	}
+
+	// Only the first file of the package runs the code and imports strings
+	if want := []string{"api/v1/orders.proto"}; !slices.Equal(calls, want) {
+		t.Errorf("DeclareOnce ran for %q, want %q", calls, want)
+	}
+
+	want := map[string]string{
+		"orders.pb.go": "package orders\n\nimport (\n\tstrings \"strings\"\n)\n\nfunc upper(s string) string { return strings.ToUpper(s) }\n",
+		"users.pb.go":  "package orders\n",
+	}
+
+	for _, f := range resp.GetFile() {
+		if got := f.GetContent(); got != want[f.GetName()] {
+			t.Errorf("%s = %q, want %q", f.GetName(), got, want[f.GetName()])
+		}
+	}
+
+	parallel, err := p(4).Generate(testRequest(samePackage))
+	if err != nil || !proto.Equal(parallel, resp) {
+		t.Errorf("parallel Generate() = %v, %v, want %v", parallel, err, resp)
+	}
+
+	// Files in different packages each get their own copy
+	resp, err = p(0).Generate(testRequest(""))
+	if err != nil || resp.Error != nil {
+		t.Fatalf("Generate() = %v, %v", resp.GetError(), err)
+	}
+
+	for _, f := range resp.GetFile() {
+		if !strings.Contains(f.GetContent(), "func upper") {
+			t.Errorf("%s does not declare upper", f.GetName())
+		}
+	}
+}
+
+func TestDeclarationErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		param   string
+		declare func(ctx *Context, file *File)
+		want    string
+	}{
+		{
+			name:    "two files",
+			param:   samePackage,
+			declare: func(ctx *Context, _ *File) { ctx.Declare("helper") },
+			want:    `helper is declared twice in package "example.com/api/v1/orders": by generator "api/**" (api/v1/orders.proto) and by generator "api/**" (api/v2/users.proto)`,
+		},
+		{
+			name:  "declare and declare once",
+			param: samePackage,
+			declare: func(ctx *Context, file *File) {
+				if file.Name == "api/v2/users.proto" {
+					ctx.Declare("helper")
+
+					return
+				}
+
+				ctx.DeclareOnce("helper", func(*CodeBuilder) {})
+			},
+			want: `helper is declared twice in package "example.com/api/v1/orders": by generator "api/**" (api/v1/orders.proto) and by generator "api/**" (api/v2/users.proto)`,
+		},
+		{
+			name:    "different packages",
+			declare: func(ctx *Context, _ *File) { ctx.Declare("helper") },
+		},
+		{
+			name: "message",
+			declare: func(ctx *Context, file *File) {
+				if file.Name == "api/v2/users.proto" {
+					ctx.Declare("User")
+				}
+			},
+			want: `User is declared twice in package "example.com/api/v2/users": by protoc-gen-go (api/v2/users.proto) and by generator "api/**" (api/v2/users.proto)`,
+		},
+		{
+			name:  "generated in another file",
+			param: samePackage,
+			declare: func(ctx *Context, file *File) {
+				if file.Name == "api/v2/users.proto" {
+					ctx.Declare("Order_Item")
+				}
+			},
+			want: `Order_Item is declared twice in package "example.com/api/v1/orders": by protoc-gen-go (api/v1/orders.proto) and by generator "api/**" (api/v2/users.proto)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := NewPlugin().
+				GenerateFor("api/**", func(ctx *Context, file *File) error {
+					ctx.Code().Package(file.Package()).Generate()
+					tt.declare(ctx, file)
+
+					return nil
+				}).
+				Generate(testRequest(tt.param))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := resp.GetError(); got != tt.want {
+				t.Errorf("Generate() error = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeneratedNames(t *testing.T) {
+	tests := []struct {
+		name      string
+		generated bool
+	}{
+		{"Order", true}, {"Order_Item", true}, {"Order_State", true}, {"Order_State_name", true}, {"Order_State_value", true},
+		{"Order_State_UNSPECIFIED", true}, {"Status", true}, {"Status_Status_UNSPECIFIED", true},
+		{"File_api_v1_orders_proto", true}, {"file_api_v1_orders_proto_rawDesc", true}, {"file_api_v1_orders_proto_goTypes", true},
+		{"X", true}, {"X_Id", true}, {"isX_Kind", true},
+		{"E_Tag", true}, {"E_X_Note", true}, {"Default_X_Count", true},
+		{"X_State", true}, {"X_State_", true},
+		{"Tag", false}, {"X_Note", false}, {"X_Count", false},
+	}
+
+	for _, tt := range tests {
+		resp, err := NewPlugin().
+			GenerateFor("**", func(ctx *Context, file *File) error {
+				if file.Name == "internal/x.proto" {
+					ctx.Code().Package("x").Generate()
+					ctx.Declare(tt.name)
+				}
+
+				return nil
+			}).
+			Generate(testNamesRequest(`Minternal/x.proto=example.com/api/v1/orders;orders`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := strings.HasPrefix(resp.GetError(), tt.name+" is declared twice"); got != tt.generated {
+			t.Errorf("Declare(%q) error = %q, want a collision with protoc-gen-go: %t", tt.name, resp.GetError(), tt.generated)
+		}
+	}
+}
+
+func TestAllocateName(t *testing.T) {
+	var got []string
+
+	resp, err := NewPlugin().
+		GenerateFor("api/v1/*", func(ctx *Context, _ *File) error {
+			ctx.Code().Package("orders").Generate()
+			ctx.Declare("helper")
+
+			for _, base := range []string{"value", "value", "string", "type", "_", "9 lives", "Order", "helper", "file_api_v1_orders_proto_rawDesc"} {
+				got = append(got, ctx.AllocateName(base))
+			}
+
+			return nil
+		}).
+		Generate(testRequest(""))
+	if err != nil || resp.Error != nil {
+		t.Fatalf("Generate() = %v, %v", resp.GetError(), err)
+	}
+
+	want := []string{"value", "value1", "string1", "type1", "_1", "_9_lives", "Order1", "helper1", "file_api_v1_orders_proto_rawDesc1"}
+	if !slices.Equal(got, want) {
+		t.Errorf("AllocateName() = %q, want %q", got, want)
+	}
+}
+
+func TestAllocateNameAcrossFiles(t *testing.T) {
+	var got []string
+
+	resp, err := NewPlugin().
+		GenerateFor("api/**", func(ctx *Context, file *File) error {
+			ctx.Code().Package("orders").Generate()
+
+			if file.Name == "api/v1/orders.proto" {
+				ctx.Declare("helper")
+				ctx.DeclareOnce("ptr", func(cb *CodeBuilder) { cb.Line("func ptr[T any](v T) *T { return &v }") })
+
+				return nil
+			}
+
+			// Names declared by another file of the package are taken
+			for _, base := range []string{"helper", "ptr", "value"} {
+				got = append(got, ctx.AllocateName(base))
+			}
+
+			return nil
+		}).
+		Generate(testRequest(samePackage))
+	if err != nil || resp.Error != nil {
+		t.Fatalf("Generate() = %v, %v", resp.GetError(), err)
+	}
+
+	want := []string{"helper1", "ptr1", "value"}
+	if !slices.Equal(got, want) {
+		t.Errorf("AllocateName() = %q, want %q", got, want)
+	}
+}
+
+// testOneofRequest returns testRequest(param) with internal.X's id field
+// moved into a oneof named kind.
+func testOneofRequest(param string) *pluginpb.CodeGeneratorRequest {
+	req := testRequest(param)
+
+	x := req.GetProtoFile()[4].GetMessageType()[0]
+	x.OneofDecl = []*descriptorpb.OneofDescriptorProto{{Name: proto.String("kind")}}
+	x.Field[0].OneofIndex = proto.Int32(0)
+
+	return req
+}
+
+// testNamesRequest returns testOneofRequest(param) with internal/x.proto
+// turned into a proto2 file declaring an extension at file and message
+// level, a field with a default value and a oneof field of a nested enum
+// type named after that enum.
+func testNamesRequest(param string) *pluginpb.CodeGeneratorRequest {
+	req := testOneofRequest(param)
+
+	file := req.GetProtoFile()[4]
+	file.Syntax = proto.String("proto2")
+	file.Extension = []*descriptorpb.FieldDescriptorProto{testExtension("tag", ".internal.X", 100)}
+
+	x := file.MessageType[0]
+	x.ExtensionRange = []*descriptorpb.DescriptorProto_ExtensionRange{{Start: proto.Int32(100), End: proto.Int32(200)}}
+	x.Extension = []*descriptorpb.FieldDescriptorProto{testExtension("note", ".internal.X", 101)}
+	x.EnumType = []*descriptorpb.EnumDescriptorProto{testEnum("State")}
+	x.Field = append(x.Field,
+		&descriptorpb.FieldDescriptorProto{
+			Name:       proto.String("state"),
+			JsonName:   proto.String("state"),
+			Number:     proto.Int32(2),
+			Label:      descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:       descriptorpb.FieldDescriptorProto_TYPE_ENUM.Enum(),
+			TypeName:   proto.String(".internal.X.State"),
+			OneofIndex: proto.Int32(0),
+		},
+		&descriptorpb.FieldDescriptorProto{
+			Name:         proto.String("count"),
+			JsonName:     proto.String("count"),
+			Number:       proto.Int32(3),
+			Label:        descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:         descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(),
+			DefaultValue: proto.String("5"),
+		},
+	)
+
+	return req
+}
@@ -0,0 +1,324 @@
+package ezproto
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Tags is a struct tag built key by key. Keys keep the order in which they
+// were first added, and adding a key again replaces its name and merges its
+// options.
+type Tags struct {
+	keys   []string
+	values map[string][]string
+	// rest holds the malformed tail of a parsed tag, which is kept verbatim.
+	rest string
+}
+
+// ParseTags parses a struct tag such as `json:"name,omitempty" db:"name"`,
+// with or without the surrounding backticks, so that it can be extended.
+func ParseTags(tag string) *Tags {
+	t := &Tags{}
+	s := strings.TrimSpace(strings.Trim(tag, "`"))
+
+	for s != "" {
+		key, value, tail, ok := nextTag(s)
+		if !ok {
+			t.rest = s
+
+			break
+		}
+
+		t.Add(key, strings.Split(value, ",")...)
+		s = strings.TrimLeft(tail, " ")
+	}
+
+	return t
+}
+
+// nextTag splits the first key:"value" pair off a struct tag, following the
+// conventions of reflect.StructTag.
+func nextTag(s string) (key, value, tail string, ok bool) {
+	i := 0
+	for i < len(s) && s[i] > ' ' && s[i] != ':' && s[i] != '"' && s[i] != 0x7f {
+		i++
+	}
+
+	if i == 0 || i+1 >= len(s) || s[i] != ':' || s[i+1] != '"' {
+		return "", "", "", false
+	}
+
+	key, s = s[:i], s[i+1:]
+
+	// Find the closing quote, skipping escaped characters
+	i = 1
+	for i < len(s) && s[i] != '"' {
+		if s[i] == '\\' {
+			i++
+		}
+
+		i++
+	}
+
+	if i >= len(s) {
+		return "", "", "", false
+	}
+
+	value, err := strconv.Unquote(s[:i+1])
+	if err != nil {
+		return "", "", "", false
+	}
+
+	return key, value, s[i+1:], true
+}
+
+// Add adds comma-separated values for key, such as Add("json", "id",
+// "omitempty") for `json:"id,omitempty"`. The first value is the name. If
+// key is already present, a non-empty name replaces its name, so that
+// Add("json", "id") renames the field, and the remaining options are
+// appended, skipping options it already has.
+func (t *Tags) Add(key string, values ...string) *Tags {
+	if t.values == nil {
+		t.values = make(map[string][]string)
+	}
+
+	existing, ok := t.values[key]
+	if !ok {
+		t.keys = append(t.keys, key)
+	}
+
+	if len(values) == 0 {
+		t.values[key] = existing
+
+		return t
+	}
+
+	switch name := values[0]; {
+	case len(existing) == 0:
+		existing = []string{name}
+	case name != "":
+		existing[0] = name
+	}
+
+	for _, option := range values[1:] {
+		if !slices.Contains(existing[1:], option) {
+			existing = append(existing, option)
+		}
+	}
+
+	t.values[key] = existing
+
+	return t
+}
+
+// Set replaces the values of key, keeping its position.
+func (t *Tags) Set(key string, values ...string) *Tags {
+	if _, ok := t.values[key]; ok {
+		t.values[key] = append([]string(nil), values...)
+
+		return t
+	}
+
+	return t.Add(key, values...)
+}
+
+// Delete removes key from the tag.
+func (t *Tags) Delete(key string) *Tags {
+	if _, ok := t.values[key]; ok {
+		delete(t.values, key)
+		t.keys = slices.DeleteFunc(t.keys, func(k string) bool { return k == key })
+	}
+
+	return t
+}
+
+// Get returns the value of key as it appears in the tag, joined by commas.
+func (t *Tags) Get(key string) (string, bool) {
+	values, ok := t.values[key]
+
+	return strings.Join(values, ","), ok
+}
+
+// Merge adds every key of other, as if by Add.
+func (t *Tags) Merge(other *Tags) *Tags {
+	for _, key := range other.keys {
+		t.Add(key, other.values[key]...)
+	}
+
+	if other.rest != "" {
+		t.rest = strings.TrimSpace(t.rest + " " + other.rest)
+	}
+
+	return t
+}
+
+// String returns the tag content without the enclosing literal, such as
+// json:"id,omitempty" db:"id". Values are quoted and escaped like Go strings.
+func (t *Tags) String() string {
+	parts := make([]string, 0, len(t.keys)+1)
+	for _, key := range t.keys {
+		parts = append(parts, key+":"+strconv.Quote(strings.Join(t.values[key], ",")))
+	}
+
+	if t.rest != "" {
+		parts = append(parts, t.rest)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// Literal returns the tag as a Go string literal: a raw string in
+// backticks, or an interpreted string if the tag itself contains a backtick.
+// It returns an empty string for an empty tag.
+func (t *Tags) Literal() string {
+	s := t.String()
+
+	switch {
+	case s == "":
+		return ""
+	case strings.Contains(s, "`"):
+		return strconv.Quote(s)
+	default:
+		return "`" + s + "`"
+	}
+}
+
+// FieldTagOption customizes the tags derived by FieldTags.
+type FieldTagOption func(f *Field, tags *Tags)
+
+// FieldTags derives conventional struct tags from a proto field: json with
+// the field's JSON name and omitempty, and db with the snake_case field name.
+// Options are applied in order and can override or add tags.
+func FieldTags(f *Field, opts ...FieldTagOption) *Tags {
+	tags := &Tags{}
+	tags.Add("json", f.JSONName(), "omitempty")
+	tags.Add("db", toSnakeCase(f.Name))
+
+	for _, opt := range opts {
+		opt(f, tags)
+	}
+
+	return tags
+}
+
+// TagFromOption names the tag key after the value of a field option, when
+// the option is set. Custom options are written in parentheses:
+//
+//	ezproto.FieldTags(field, ezproto.TagFromOption("db", "(acme.orders.column)"))
+//
+// A value of "-" omits the field from the encoding, as usual.
+func TagFromOption(key, option string) FieldTagOption {
+	return func(f *Field, tags *Tags) {
+		value, ok := optionValue(f, option)
+		if !ok {
+			return
+		}
+
+		// Options such as omitempty are kept unless the field is omitted
+		if value == "-" {
+			tags.Set(key, value)
+
+			return
+		}
+
+		tags.Add(key, value)
+	}
+}
+
+// WithTag adds a tag to every field, as if by Tags.Add.
+func WithTag(key string, values ...string) FieldTagOption {
+	return func(_ *Field, tags *Tags) {
+		tags.Add(key, values...)
+	}
+}
+
+// WithoutTag removes a tag added by default or by an earlier option.
+func WithoutTag(key string) FieldTagOption {
+	return func(_ *Field, tags *Tags) {
+		tags.Delete(key)
+	}
+}
+
+// optionValue returns the value of an element's option, formatted as in a
+// .proto file. Extensions are named in parentheses, such as (acme.column).
+func optionValue(el Element, name string) (string, bool) {
+	opts := elementOptions(el)
+	if opts == nil {
+		return "", false
+	}
+
+	var (
+		value string
+		found bool
+	)
+
+	opts.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if optionName(fd) != name {
+			return true
+		}
+
+		value, found = formatOptionValue(fd, v), true
+
+		return false
+	})
+
+	return value, found
+}
+
+// structField is the last field written by a StructBuilder, kept so that
+// tags can still be added to it.
+type structField struct {
+	line   int
+	indent string
+	decl   string
+	tags   *Tags
+}
+
+func (f *structField) String() string {
+	if lit := f.tags.Literal(); lit != "" {
+		return f.indent + f.decl + " " + lit
+	}
+
+	return f.indent + f.decl
+}
+
+// Tag adds a tag to the last field added to the struct. Values are merged
+// into an existing key as described for Tags.Add:
+//
+//	sb.Field("ID", "int64").Tag("json", "id", "omitempty").Tag("db", "id")
+func (sb *StructBuilder) Tag(key string, values ...string) *StructBuilder {
+	return sb.updateLast(func(tags *Tags) { tags.Add(key, values...) })
+}
+
+// Tags merges tags into the tag of the last field added to the struct.
+func (sb *StructBuilder) Tags(tags *Tags) *StructBuilder {
+	return sb.updateLast(func(t *Tags) { t.Merge(tags) })
+}
+
+// updateLast edits the tag of the last field and rewrites its line.
+func (sb *StructBuilder) updateLast(update func(*Tags)) *StructBuilder {
+	if sb.last == nil {
+		return sb
+	}
+
+	update(sb.last.tags)
+	sb.cb.lines[sb.last.line] = sb.last.String()
+
+	return sb
+}
+
+// addField writes a field line and remembers it for Tag.
+func (sb *StructBuilder) addField(decl string, tags []string) {
+	f := &structField{
+		line:   len(sb.cb.lines),
+		indent: strings.Repeat("\t", sb.cb.indent),
+		decl:   decl,
+		tags:   ParseTags(strings.Join(tags, " ")),
+	}
+
+	sb.cb.lines = append(sb.cb.lines, f.String())
+	sb.last = f
+}
@@ -0,0 +1,137 @@
+package ezproto
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func TestTags(t *testing.T) {
+	tests := []struct {
+		name string
+		tags *Tags
+		want string
+	}{
+		{"parse", ParseTags("`json:\"id,omitempty\"  db:\"id\"`"), `json:"id,omitempty" db:"id"`},
+		{"escaped", ParseTags(`q:"a\"b"`), `q:"a\"b"`},
+		{"malformed tail", ParseTags(`json:"id" broken`), `json:"id" broken`},
+		{"add options", ParseTags(`json:"id"`).Add("json", "", "omitempty"), `json:"id,omitempty"`},
+		{"replace name", ParseTags(`json:"foo"`).Add("json", "bar"), `json:"bar"`},
+		{"replace name keeps options", ParseTags(`json:"foo,omitempty"`).Add("json", "bar", "string", "omitempty"), `json:"bar,omitempty,string"`},
+		{"name of empty tag", ParseTags(`json:",omitempty"`).Add("json", "id"), `json:"id,omitempty"`},
+		{"new key", ParseTags(`json:"id"`).Add("db", "id"), `json:"id" db:"id"`},
+		{"set", ParseTags(`json:"id,omitempty" db:"id"`).Set("json", "-"), `json:"-" db:"id"`},
+		{"delete", ParseTags(`json:"id" db:"id"`).Delete("json"), `db:"id"`},
+		{"merge", ParseTags(`json:"id"`).Merge(ParseTags(`json:",omitempty" xml:"id" broken`)), `json:"id,omitempty" xml:"id" broken`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.tags.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagsLiteral(t *testing.T) {
+	tests := []struct {
+		tags *Tags
+		want string
+	}{
+		{&Tags{}, ""},
+		{ParseTags(`json:"id"`), "`json:\"id\"`"},
+		{(&Tags{}).Add("q", "a`b"), `"q:\"a` + "`" + `b\""`},
+	}
+
+	for _, tt := range tests {
+		if got := tt.tags.Literal(); got != tt.want {
+			t.Errorf("Literal() = %s, want %s", got, tt.want)
+		}
+	}
+}
+
+func TestFieldTags(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []FieldTagOption
+		want string
+	}{
+		{"defaults", nil, `json:"customerName,omitempty" db:"customer_name"`},
+		{"option", []FieldTagOption{TagFromOption("db", "(acme.column)")}, `json:"customerName,omitempty" db:"customer"`},
+		{"option keeps options", []FieldTagOption{TagFromOption("json", "(acme.column)")}, `json:"customer,omitempty" db:"customer_name"`},
+		{"unset option", []FieldTagOption{TagFromOption("db", "(acme.table)")}, `json:"customerName,omitempty" db:"customer_name"`},
+		{"with and without", []FieldTagOption{WithTag("validate", "required"), WithoutTag("db")}, `json:"customerName,omitempty" validate:"required"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+
+			resp, err := NewPlugin().
+				ForEachMessage("internal.X", func(_ *Context, msg *Message) error {
+					got = FieldTags(msg.Fields()[1], tt.opts...).String()
+
+					return nil
+				}).
+				Generate(testTagsRequest())
+			if err != nil || resp.Error != nil {
+				t.Fatalf("Generate() = %v, %v", resp.GetError(), err)
+			}
+
+			if got != tt.want {
+				t.Errorf("FieldTags() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructBuilderTag(t *testing.T) {
+	content := renderFor(t, func(cb *CodeBuilder) {
+		cb.Struct("T", func(sb *StructBuilder) {
+			sb.Field("ID", "int64").Tag("json", "id").Tag("json", "", "omitempty").Tag("db", "id")
+			sb.Field("Raw", "string", `json:"raw"`).Tag("json", "renamed").Tag("q", "a`b")
+		})
+	})
+
+	for _, want := range []string{
+		"ID  int64  `json:\"id,omitempty\" db:\"id\"`",
+		"Raw string \"json:\\\"renamed\\\" q:\\\"a`b\\\"\"",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated\n%s\nwant it to contain %s", content, want)
+		}
+	}
+}
+
+// testTagsRequest returns testRequest with a string field option
+// (acme.column) = "customer" on the customer_name field of internal.X.
+func testTagsRequest() *pluginpb.CodeGeneratorRequest {
+	req := testRequest("")
+
+	options := req.GetProtoFile()[1]
+	column := testExtension("column", ".google.protobuf.FieldOptions", 50002)
+	column.Type = descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()
+	options.Extension = append(options.Extension, column)
+
+	fieldOptions := &descriptorpb.FieldOptions{}
+	b := protowire.AppendTag(nil, 50002, protowire.BytesType)
+	fieldOptions.ProtoReflect().SetUnknown(protowire.AppendString(b, "customer"))
+
+	x := req.GetProtoFile()[4]
+	x.Dependency = []string{"acme/options.proto"}
+	x.MessageType[0].Field = append(x.MessageType[0].Field, &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String("customer_name"),
+		JsonName: proto.String("customerName"),
+		Number:   proto.Int32(2),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+		Options:  fieldOptions,
+	})
+
+	return req
+}
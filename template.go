@@ -0,0 +1,296 @@
+package ezproto
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// WithTemplatesFS parses every file in fsys as a template named after its
+// path, such as "templates/message.go.tmpl", for use with ExecuteTemplate.
+// It is typically given an embed.FS. Templates from several file systems
+// share one namespace, so they can invoke each other with
+// {{template "name" .}}. Parse errors are reported when generation starts.
+func (p *Plugin) WithTemplatesFS(fsys fs.FS) *Plugin {
+	if p.templates == nil {
+		p.templates = template.New("").Funcs((&CodeBuilder{}).templateFuncs())
+	}
+
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		text, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+
+		_, err = p.templates.New(path).Parse(string(text))
+
+		return err
+	})
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("failed to parse templates: %w", err))
+	}
+
+	return p
+}
+
+// ExecuteTemplate renders a template into the output file like a
+// CodeBuilder, so the output is formatted and its imports are managed in
+// the same way. If text is empty, name refers to a template registered with
+// WithTemplatesFS; otherwise text is parsed as a template called name,
+// which may invoke the registered ones. The template functions are
+// described at CodeBuilder.Template.
+func (c *Context) ExecuteTemplate(name, text string, data any) error {
+	cb := c.Code()
+	if err := cb.Template(name, text, data); err != nil {
+		return err
+	}
+
+	cb.Generate()
+
+	return nil
+}
+
+// Template renders a template, as described at Context.ExecuteTemplate,
+// and adds its output at the current indentation. Besides the text/template
+// builtins, templates can call:
+//
+//	snakeCase, camelCase, pascalCase  rename: {{pascalCase .Name}}
+//	goType                            Go type of a field: {{goType .}}
+//	goIdent                           Go name of a message, enum or enum value
+//	import                            package qualifier: {{import "time"}}Duration
+//	qualify                           identifier: {{qualify "time" "Duration"}}
+//	comments                          leading comments of an element
+//	comment                           text as // comment lines
+//	option, hasOption                 option value: {{option . "(acme.column)"}}
+//	quote, rawOrQuoted                Go string literals
+//
+// Types and identifiers from other packages are qualified for the output
+// file and imported automatically.
+func (cb *CodeBuilder) Template(name, text string, data any) error {
+	t, err := cb.ctx.plugin.template(name, text)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Funcs(cb.templateFuncs()).ExecuteTemplate(&buf, name, data); err != nil {
+		return err
+	}
+
+	cb.Verbatim(strings.TrimSuffix(buf.String(), "\n"))
+
+	return nil
+}
+
+// template returns a private copy of the registered templates containing
+// name, so that its functions can be bound to one builder.
+func (p *Plugin) template(name, text string) (*template.Template, error) {
+	base := p.templates
+	if base == nil {
+		base = template.New("").Funcs((&CodeBuilder{}).templateFuncs())
+	}
+
+	t, err := base.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	if text != "" {
+		return t.New(name).Parse(text)
+	}
+
+	if t.Lookup(name) == nil {
+		return nil, fmt.Errorf("template %q not found", name)
+	}
+
+	return t, nil
+}
+
+// templateFuncs returns the template functions bound to the builder.
+func (cb *CodeBuilder) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"snakeCase":  toSnakeCase,
+		"camelCase":  toCamelCase,
+		"pascalCase": toPascalCase,
+		"goType": func(f *Field) string {
+			return fieldGoType(cb.Qualify, f.proto)
+		},
+		"goIdent": cb.goIdent,
+		"import": func(importPath string) string {
+			return cb.ctx.Import(importPath)
+		},
+		"qualify": func(importPath, name string) string {
+			return cb.Qualify(Ident(importPath, name))
+		},
+		"comments": leadingComments,
+		"comment": func(text string) string {
+			lines := splitCommentLines(text)
+			for i, line := range lines {
+				lines[i] = strings.TrimRight("// "+line, " ")
+			}
+
+			return strings.Join(lines, "\n")
+		},
+		"option": func(el Element, name string) string {
+			value, _ := optionValue(el, name)
+
+			return value
+		},
+		"hasOption": func(el Element, name string) bool {
+			_, ok := optionValue(el, name)
+
+			return ok
+		},
+		"quote":       Quote,
+		"rawOrQuoted": RawOrQuoted,
+	}
+}
+
+// goIdent returns the qualified Go name of a message, enum or enum value.
+func (cb *CodeBuilder) goIdent(el Element) (string, error) {
+	switch el := el.(type) {
+	case *Message:
+		return cb.Qualify(el.proto.GoIdent), nil
+	case *Enum:
+		return cb.Qualify(el.proto.GoIdent), nil
+	case *EnumValue:
+		return cb.Qualify(el.proto.GoIdent), nil
+	default:
+		return "", fmt.Errorf("goIdent: %T has no Go identifier", el)
+	}
+}
+
+// fieldGoType returns the Go type protoc-gen-go uses for a field.
+func fieldGoType(qualify func(protogen.GoIdent) string, field *protogen.Field) string {
+	desc := field.Desc
+
+	switch {
+	case desc.IsMap():
+		return "map[" + elementGoType(qualify, field.Message.Fields[0]) + "]" + elementGoType(qualify, field.Message.Fields[1])
+	case desc.IsList():
+		return "[]" + elementGoType(qualify, field)
+	case desc.HasPresence() && desc.Message() == nil && desc.Kind() != protoreflect.BytesKind &&
+		(field.Oneof == nil || field.Oneof.Desc.IsSynthetic()):
+		return "*" + elementGoType(qualify, field)
+	default:
+		return elementGoType(qualify, field)
+	}
+}
+
+// elementGoType returns the Go type of a single value of a field.
+func elementGoType(qualify func(protogen.GoIdent) string, field *protogen.Field) string {
+	switch field.Desc.Kind() {
+	case protoreflect.EnumKind:
+		return qualify(field.Enum.GoIdent)
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return "*" + qualify(field.Message.GoIdent)
+	default:
+		return scalarGoTypes[field.Desc.Kind()]
+	}
+}
+
+var scalarGoTypes = map[protoreflect.Kind]string{
+	protoreflect.BoolKind:     "bool",
+	protoreflect.Int32Kind:    "int32",
+	protoreflect.Sint32Kind:   "int32",
+	protoreflect.Sfixed32Kind: "int32",
+	protoreflect.Uint32Kind:   "uint32",
+	protoreflect.Fixed32Kind:  "uint32",
+	protoreflect.Int64Kind:    "int64",
+	protoreflect.Sint64Kind:   "int64",
+	protoreflect.Sfixed64Kind: "int64",
+	protoreflect.Uint64Kind:   "uint64",
+	protoreflect.Fixed64Kind:  "uint64",
+	protoreflect.FloatKind:    "float32",
+	protoreflect.DoubleKind:   "float64",
+	protoreflect.StringKind:   "string",
+	protoreflect.BytesKind:    "[]byte",
+}
+
+// leadingComments returns the comment written above an element in its
+// proto source, without comment markers.
+func leadingComments(el Element) string {
+	desc := descriptor(el)
+	if desc == nil {
+		return ""
+	}
+
+	text := desc.ParentFile().SourceLocations().ByDescriptor(desc).LeadingComments
+	lines := splitCommentLines(strings.TrimSuffix(text, "\n"))
+
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, " ")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// toPascalCase converts a proto name such as order_id into OrderId, the way
+// protoc-gen-go names messages and fields. It follows GoCamelCase from the
+// protobuf module: an underscore before a lower case letter is dropped, a
+// leading underscore becomes X, a letter after a digit starts a new word
+// and dots become underscores unless a lower case letter follows them.
+func toPascalCase(s string) string {
+	var b []byte
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case c == '.' && i+1 < len(s) && isASCIILower(s[i+1]):
+			// Skip over '.' in ".{{lowercase}}"
+		case c == '.':
+			b = append(b, '_')
+		case c == '_' && (i == 0 || s[i-1] == '.'):
+			b = append(b, 'X')
+		case c == '_' && i+1 < len(s) && isASCIILower(s[i+1]):
+			// Skip over '_' in "_{{lowercase}}"
+		case isASCIIDigit(c):
+			b = append(b, c)
+		default:
+			// A word starts upper case and takes the lower case letters after it
+			if isASCIILower(c) {
+				c -= 'a' - 'A'
+			}
+
+			b = append(b, c)
+
+			for ; i+1 < len(s) && isASCIILower(s[i+1]); i++ {
+				b = append(b, s[i+1])
+			}
+		}
+	}
+
+	return string(b)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// toCamelCase converts a proto name such as order_id into orderId.
+func toCamelCase(s string) string {
+	s = toPascalCase(s)
+	if s == "" {
+		return ""
+	}
+
+	r, size := utf8.DecodeRuneInString(s)
+
+	return string(unicode.ToLower(r)) + s[size:]
+}
@@ -0,0 +1,175 @@
+package ezproto
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestCaseConversion(t *testing.T) {
+	tests := []struct {
+		in     string
+		pascal string
+		camel  string
+	}{
+		{"order_id", "OrderId", "orderId"},
+		{"user_v2_id", "UserV2Id", "userV2Id"},
+		{"a1b", "A1B", "a1B"},
+		{"_foo", "XFoo", "xFoo"},
+		{"foo.bar", "FooBar", "fooBar"},
+		{"foo.Bar", "Foo_Bar", "foo_Bar"},
+		{"foo__bar", "Foo_Bar", "foo_Bar"},
+		{"x_1", "X_1", "x_1"},
+		{"foo_", "Foo_", "foo_"},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := toPascalCase(tt.in); got != tt.pascal {
+			t.Errorf("toPascalCase(%q) = %q, want %q", tt.in, got, tt.pascal)
+		}
+
+		if got := toCamelCase(tt.in); got != tt.camel {
+			t.Errorf("toCamelCase(%q) = %q, want %q", tt.in, got, tt.camel)
+		}
+	}
+
+	// Field names match the ones protoc-gen-go generates
+	req := testRequest("")
+	x := req.GetProtoFile()[4].GetMessageType()[0]
+
+	for i, name := range []string{"a1b", "_foo", "foo__bar", "x_1", "user_v2_id"} {
+		x.Field = append(x.Field, &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(int32(i + 2)),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+		})
+	}
+
+	resp, err := NewPlugin().
+		ForEachMessage("internal.X", func(_ *Context, msg *Message) error {
+			for _, f := range msg.Fields() {
+				if got := toPascalCase(f.Name); got != f.GoName() {
+					t.Errorf("toPascalCase(%q) = %q, want %q", f.Name, got, f.GoName())
+				}
+			}
+
+			return nil
+		}).
+		Generate(req)
+	if err != nil || resp.Error != nil {
+		t.Fatalf("Generate() = %v, %v", resp.GetError(), err)
+	}
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	req := testTagsRequest()
+	req.GetProtoFile()[4].SourceCodeInfo = &descriptorpb.SourceCodeInfo{
+		Location: []*descriptorpb.SourceCodeInfo_Location{{
+			Path:            []int32{4, 0},
+			Span:            []int32{2, 0, 5, 1},
+			LeadingComments: proto.String(" X is a test message.\n Second line.\n"),
+		}},
+	}
+
+	fsys := fstest.MapFS{
+		"templates/view.tmpl": {Data: []byte(`{{comment (comments .)}}
+type {{pascalCase .Name}}View struct {
+{{- range .Fields}}
+	{{pascalCase .Name}} {{goType .}} ` + "`" + `json:"{{camelCase .Name}}"{{if hasOption . "(acme.column)"}} db:"{{option . "(acme.column)"}}"{{end}}` + "`" + `
+{{- end}}
+	At {{import "time"}}Time
+	D  {{qualify "time" "Duration"}}
+}
+`)},
+	}
+
+	p := func(concurrency int) *Plugin {
+		return NewPlugin().WithOptions(Options{Concurrency: concurrency}).WithTemplatesFS(fsys).
+			GenerateFor("internal/*", func(ctx *Context, file *File) error {
+				ctx.Code().Package("x").Generate()
+
+				if err := ctx.ExecuteTemplate("templates/view.tmpl", "", file.Messages()[0]); err != nil {
+					return err
+				}
+
+				return ctx.ExecuteTemplate("inline", `{{template "templates/view.tmpl" index .Messages 0 -}}
+var _ = {{goIdent (index .Messages 0)}}{}
+
+const (
+	Snake = {{quote (snakeCase "HTTPPort")}}
+	Raw   = {{rawOrQuoted "a\nb"}}
+)`, file)
+			})
+	}
+
+	resp, err := p(0).Generate(req)
+	if err != nil || resp.Error != nil {
+		t.Fatalf("Generate() = %v, %v", resp.GetError(), err)
+	}
+
+	view := `// X is a test message.
+// Second line.
+type XView struct {
+	Id           string ` + "`" + `json:"id"` + "`" + `
+	CustomerName string ` + "`" + `json:"customerName" db:"customer"` + "`" + `
+	At           time.Time
+	D            time.Duration
+}
+`
+	want := "package x\n\nimport (\n\ttime \"time\"\n)\n\n" + view + "\n" + view + "\nvar _ = X{}\n\nconst (\n\tSnake = \"http_port\"\n\tRaw   = `a\nb`\n)\n"
+	if got := resp.GetFile()[0].GetContent(); got != want {
+		t.Errorf("generated\n%s\nwant\n%s", got, want)
+	}
+
+	parallel, err := p(4).Generate(req)
+	if err != nil || !proto.Equal(parallel, resp) {
+		t.Errorf("parallel Generate() = %v, %v, want %v", parallel, err, resp)
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		plugin *Plugin
+		want   string
+	}{
+		{
+			name:   "parse",
+			plugin: NewPlugin().WithTemplatesFS(fstest.MapFS{"bad.tmpl": {Data: []byte("{{")}}),
+			want:   "failed to parse templates: template: bad.tmpl:1: unclosed action",
+		},
+		{
+			name: "missing",
+			plugin: NewPlugin().GenerateFor("internal/*", func(ctx *Context, _ *File) error {
+				return ctx.ExecuteTemplate("missing.tmpl", "", nil)
+			}),
+			want: `generator failed for internal/x.proto: template "missing.tmpl" not found`,
+		},
+		{
+			name: "goIdent of a field",
+			plugin: NewPlugin().ForEachMessage("internal.X", func(ctx *Context, msg *Message) error {
+				return ctx.ExecuteTemplate("ident", "{{goIdent .}}", msg.Fields()[0])
+			}),
+			want: "goIdent: *ezproto.Field has no Go identifier",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tt.plugin.Generate(testRequest(""))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := resp.GetError(); !strings.Contains(got, tt.want) {
+				t.Errorf("Generate() error = %q, want it to contain %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -61,21 +61,23 @@ func (test *Test) TestGenerator(name, protoFile string, generator GeneratorFunc)
 		test.t.Fatalf("File %s not found in generated files", protoFile)
 	}
 
-	// Create output buffer
-	var output bytes.Buffer
+	output := &testGeneratedFile{
+		buffer:     &bytes.Buffer{},
+		importPath: file.GoImportPath,
+	}
+
+	// Build the ezproto model and look up the file wrapper
+	m := newModel(gen)
+	ezFile := m.byPath[file.Desc.Path()]
 
 	// Create ezproto context
 	ctx := &Context{
-		plugin: &Plugin{},
-		gen:    gen,
-		file:   file,
-		output: &testGeneratedFile{buffer: &output},
-	}
-
-	// Create ezproto File wrapper
-	ezFile := &File{
-		proto: file,
-		Name:  file.Proto.GetName(),
+		plugin:     &Plugin{symbols: newSymbolTable(gen)},
+		gen:        gen,
+		file:       file,
+		model:      m,
+		importPath: file.GoImportPath,
+		output:     output,
 	}
 
 	// Execute generator
@@ -84,13 +86,21 @@ func (test *Test) TestGenerator(name, protoFile string, generator GeneratorFunc)
 		test.t.Fatalf("Generator failed: %v", err)
 	}
 
-	// Compare with golden file
+	// Assemble sections before comparing with the golden file
+	if err := ctx.plugin.symbols.resolve(); err != nil {
+		test.t.Fatalf("Generator failed: %v", err)
+	}
+
+	ctx.plugin.layouts.flush()
+
 	test.golden.Assert(name, output.String())
 }
 
 // testGeneratedFile implements GeneratedFile interface for testing.
 type testGeneratedFile struct {
-	buffer *bytes.Buffer
+	buffer     *bytes.Buffer
+	importPath protogen.GoImportPath
+	imports    importNames
 }
 
 // P implements the GeneratedFile interface by writing to a buffer.
@@ -106,15 +116,42 @@ func (f *testGeneratedFile) P(v ...any) {
 			f.buffer.WriteString(" ")
 		}
 
+		if ident, ok := arg.(protogen.GoIdent); ok {
+			arg = f.QualifiedGoIdent(ident)
+		}
+
 		fmt.Fprintf(f.buffer, "%v", arg)
 	}
 
 	f.buffer.WriteString("\n")
 }
 
-// QualifiedGoIdent implements the GeneratedFile interface by returning the Go name.
+// QualifiedGoIdent implements the GeneratedFile interface by qualifying the
+// identifier and recording its import, as protogen does.
 func (f *testGeneratedFile) QualifiedGoIdent(ident protogen.GoIdent) string {
-	return ident.GoName
+	return f.imports.qualify(f.importPath, ident)
+}
+
+// String returns the generated code with the recorded imports placed after
+// the package clause, or at the top if there is none.
+func (f *testGeneratedFile) String() string {
+	block := f.imports.importBlock()
+	if block == "" {
+		return f.buffer.String()
+	}
+
+	content := f.buffer.String()
+	offset := 0
+
+	for line := range strings.Lines(content) {
+		offset += len(line)
+
+		if strings.HasPrefix(line, "package ") {
+			return content[:offset] + "\n" + block + "\n" + content[offset:]
+		}
+	}
+
+	return block + "\n" + content
 }
 
 // generateCodeGeneratorRequest uses protoc to generate a proper CodeGeneratorRequest